@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/ntp"
+)
+
+// Ошибки загрузки симметричных ключей
+var (
+	ErrInvalidKeyFile = errors.New("invalid key file")
+	ErrKeyNotFound    = errors.New("key not found")
+)
+
+// Код выхода при ошибке аутентификации ответа сервера
+const exitAuthFailed = -2
+
+// Названия алгоритмов в файле ключей
+var authTypes = map[string]ntp.AuthType{
+	"MD5":    ntp.AuthMD5,
+	"SHA1":   ntp.AuthSHA1,
+	"SHA256": ntp.AuthSHA256,
+	"SHA512": ntp.AuthSHA512,
+	"AES128": ntp.AuthAES128,
+	"AES256": ntp.AuthAES256,
+}
+
+/*
+Читает симметричные ключи в формате ntp.keys:
+каждая строка содержит идентификатор ключа, алгоритм и секрет,
+например "1 SHA256 HEX:6931564b4a5a5045766c55356b30656c7666316c".
+Пустые строки и комментарии после # пропускаются.
+*/
+func ParseKeys(r io.Reader) ([]ntp.AuthOptions, error) {
+	var keys []ntp.AuthOptions
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%w: line %d: expected key id, algorithm and secret", ErrInvalidKeyFile, line)
+		}
+
+		id, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("%w: line %d: bad key id %q", ErrInvalidKeyFile, line, fields[0])
+		}
+
+		authType, ok := authTypes[strings.ToUpper(fields[1])]
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d: unknown algorithm %q", ErrInvalidKeyFile, line, fields[1])
+		}
+
+		keys = append(keys, ntp.AuthOptions{
+			Type:  authType,
+			Key:   fields[2],
+			KeyID: uint16(id),
+		})
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Загружает из файла ключ с идентификатором id, при id = 0 - первый ключ файла
+func LoadKey(fileName string, id uint16) (ntp.AuthOptions, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return ntp.AuthOptions{}, err
+	}
+	defer file.Close()
+
+	keys, err := ParseKeys(file)
+	if err != nil {
+		return ntp.AuthOptions{}, err
+	}
+
+	for _, key := range keys {
+		if id == 0 || key.KeyID == id {
+			return key, nil
+		}
+	}
+
+	return ntp.AuthOptions{}, fmt.Errorf("%w: %d", ErrKeyNotFound, id)
+}
+
+// Проверяет, отклонён ли хотя бы один ответ из-за ошибки аутентификации
+func authFailed(results []ServerResult) bool {
+	for _, result := range results {
+		if errors.Is(result.Err, ntp.ErrAuthFailed) {
+			return true
+		}
+	}
+
+	return false
+}
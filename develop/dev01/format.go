@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Ошибка неизвестного формата вывода
+var ErrUnknownFormat = errors.New("unknown output format")
+
+// Поддерживаемые форматы вывода
+const (
+	FormatText        = "text"
+	FormatRFC3339     = "rfc3339"
+	FormatRFC3339Nano = "rfc3339nano"
+	FormatUnix        = "unix"
+	FormatUnixMilli   = "unix-ms"
+	FormatUnixNano    = "unix-ns"
+	FormatJSON        = "json"
+)
+
+// Префикс произвольного layout Go в формате вывода, например layout:15:04
+const LayoutPrefix = "layout:"
+
+// Результат запроса точного времени
+type Report struct {
+	Time    time.Time     `json:"time"`
+	Offset  time.Duration `json:"offset"` // в наносекундах
+	RTT     time.Duration `json:"rtt"`    // в наносекундах
+	Server  string        `json:"server"`
+	Stratum uint8         `json:"stratum"`
+}
+
+// Формирует отчёт по ответу сервера
+func NewReport(server string, r *ntp.Response) Report {
+	return Report{
+		Time:    time.Now().Add(r.ClockOffset),
+		Offset:  r.ClockOffset,
+		RTT:     r.RTT,
+		Server:  server,
+		Stratum: r.Stratum,
+	}
+}
+
+// Время, в котором каждый элемент layout отличается от эталонного времени Go
+var formatProbe = time.Date(2001, 2, 3, 4, 5, 6, 123456789, time.UTC)
+
+/*
+Проверяет формат вывода.
+Кроме именованных форматов допускается произвольный layout Go с префиксом
+LayoutPrefix, он должен содержать хотя бы один элемент эталонного времени.
+Без префикса опечатка в имени формата вроде rfc3339 не примется за layout.
+*/
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatText, FormatRFC3339, FormatRFC3339Nano, FormatUnix, FormatUnixMilli, FormatUnixNano, FormatJSON:
+		return nil
+	}
+
+	layout, ok := strings.CutPrefix(format, LayoutPrefix)
+	if !ok || layout == "" || formatProbe.Format(layout) == layout {
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+
+	return nil
+}
+
+// Печатает отчёт в заданном формате во временной зоне loc
+func WriteReport(w io.Writer, report Report, format string, loc *time.Location) error {
+	t := report.Time.In(loc)
+
+	var err error
+	switch format {
+	case FormatText:
+		// Текущее время и точное время (с миллисекундами и наносекундами)
+		_, err = fmt.Fprintf(w, "current time: %s\nexact time: %s",
+			t.Format("15:04:05"), t.Format("15:04:05.000000000"))
+	case FormatRFC3339:
+		_, err = fmt.Fprintln(w, t.Format(time.RFC3339))
+	case FormatRFC3339Nano:
+		_, err = fmt.Fprintln(w, t.Format(time.RFC3339Nano))
+	case FormatUnix:
+		_, err = fmt.Fprintln(w, t.Unix())
+	case FormatUnixMilli:
+		_, err = fmt.Fprintln(w, t.UnixMilli())
+	case FormatUnixNano:
+		_, err = fmt.Fprintln(w, t.UnixNano())
+	case FormatJSON:
+		report.Time = t
+		err = json.NewEncoder(w).Encode(report)
+	default:
+		layout, ok := strings.CutPrefix(format, LayoutPrefix)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+		}
+		_, err = fmt.Fprintln(w, t.Format(layout))
+	}
+
+	return err
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Коды состояния монитора, которые возвращаются в OS при завершении
+const (
+	HealthOK    = 0
+	HealthAlert = 1
+	HealthError = 2
+)
+
+// Один замер смещения локальных часов
+type Sample struct {
+	Time   time.Time
+	Offset time.Duration
+}
+
+// Параметры мониторинга дрейфа часов
+type MonitorConfig struct {
+	Server      string
+	Timeout     time.Duration
+	Auth        ntp.AuthOptions
+	MinInterval time.Duration // минимальный интервал опроса
+	MaxInterval time.Duration // максимальный интервал опроса
+	MaxOffset   time.Duration // порог смещения, 0 - без проверки
+	MaxDrift    float64       // порог дрейфа в ppm, 0 - без проверки
+	History     int           // количество хранимых замеров
+}
+
+// Монитор дрейфа часов
+type Monitor struct {
+	cfg      MonitorConfig
+	query    func(host string, opt ntp.QueryOptions) (*ntp.Response, error)
+	now      func() time.Time
+	history  []Sample
+	interval time.Duration
+	status   int
+}
+
+/*
+Наименьший допустимый интервал опроса. Меньшие значения MinInterval,
+в том числе нулевое, заменяются им, чтобы монитор не опрашивал сервер непрерывно.
+*/
+const minPollInterval = time.Second
+
+// Создаёт монитор с заданными параметрами
+func NewMonitor(cfg MonitorConfig) *Monitor {
+	if cfg.MinInterval < minPollInterval {
+		cfg.MinInterval = minPollInterval
+	}
+	if cfg.MaxInterval < cfg.MinInterval {
+		cfg.MaxInterval = cfg.MinInterval
+	}
+	if cfg.History < 2 {
+		cfg.History = 2
+	}
+
+	return &Monitor{
+		cfg:      cfg,
+		query:    ntp.QueryWithOptions,
+		now:      time.Now,
+		interval: cfg.MinInterval,
+	}
+}
+
+// Текущий код состояния
+func (m *Monitor) Status() int {
+	return m.status
+}
+
+// Интервал до следующего опроса
+func (m *Monitor) Interval() time.Duration {
+	return m.interval
+}
+
+/*
+Оценивает дрейф часов в ppm методом наименьших квадратов:
+наклон прямой offset(t) по истории замеров.
+Возвращает false, если замеров недостаточно для оценки.
+*/
+func (m *Monitor) Drift() (float64, bool) {
+	if len(m.history) < 2 {
+		return 0, false
+	}
+
+	start := m.history[0].Time
+	n := float64(len(m.history))
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range m.history {
+		x := s.Time.Sub(start).Seconds()
+		y := s.Offset.Seconds()
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, false
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denom
+
+	return slope * 1e6, true
+}
+
+/*
+Выполняет один опрос NTP-сервера.
+Ошибка печатается в errOut, интервал опроса увеличивается (back-off).
+При превышении порогов в out печатается строка alert и интервал сбрасывается
+до минимального, иначе интервал удваивается до максимального.
+*/
+func (m *Monitor) Poll(out, errOut io.Writer) {
+	response, err := m.query(m.cfg.Server, ntp.QueryOptions{Timeout: m.cfg.Timeout, Auth: m.cfg.Auth})
+	if err == nil {
+		err = response.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(errOut, "%v\n", err)
+		m.status = HealthError
+		m.grow()
+		return
+	}
+
+	m.history = append(m.history, Sample{Time: m.now(), Offset: response.ClockOffset})
+	if len(m.history) > m.cfg.History {
+		m.history = m.history[len(m.history)-m.cfg.History:]
+	}
+
+	drift, ok := m.Drift()
+	fmt.Fprintf(out, "%s: offset: %v, rtt: %v", m.cfg.Server, response.ClockOffset, response.RTT)
+	if ok {
+		fmt.Fprintf(out, ", drift: %.3f ppm", drift)
+	}
+	fmt.Fprintln(out)
+
+	alert := false
+	if m.cfg.MaxOffset > 0 && absDuration(response.ClockOffset) > m.cfg.MaxOffset {
+		fmt.Fprintf(out, "alert: offset %v exceeds %v\n", response.ClockOffset, m.cfg.MaxOffset)
+		alert = true
+	}
+	if m.cfg.MaxDrift > 0 && ok && math.Abs(drift) > m.cfg.MaxDrift {
+		fmt.Fprintf(out, "alert: drift %.3f ppm exceeds %.3f ppm\n", drift, m.cfg.MaxDrift)
+		alert = true
+	}
+
+	if alert {
+		m.status = HealthAlert
+		m.interval = m.cfg.MinInterval
+		return
+	}
+
+	m.status = HealthOK
+	m.grow()
+}
+
+// Опрашивает сервер до отмены контекста и возвращает последний код состояния
+func (m *Monitor) Run(ctx context.Context, out, errOut io.Writer) int {
+	for {
+		m.Poll(out, errOut)
+
+		timer := time.NewTimer(m.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return m.status
+		case <-timer.C:
+		}
+	}
+}
+
+// Удваивает интервал опроса, не превышая максимальный
+func (m *Monitor) grow() {
+	m.interval *= 2
+	if m.interval > m.cfg.MaxInterval {
+		m.interval = m.cfg.MaxInterval
+	}
+}
+
+// Модуль длительности
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Ошибки выбора лучшего источника времени
+var (
+	ErrNoValidResponses = errors.New("no valid responses from ntp servers")
+	ErrNoMajority       = errors.New("ntp servers do not agree on time")
+)
+
+// Результат опроса одного NTP-сервера
+type ServerResult struct {
+	Server   string
+	Response *ntp.Response
+	Err      error
+}
+
+// Опрашивает каждый сервер из списка с помощью ntp.Query
+func QueryServers(servers []string, opt ntp.QueryOptions) []ServerResult {
+	results := make([]ServerResult, len(servers))
+
+	for i, server := range servers {
+		results[i].Server = server
+
+		response, err := ntp.QueryWithOptions(server, opt)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Response = response
+
+		// Ответ, непригодный для синхронизации, тоже считаем ошибкой
+		if err := response.Validate(); err != nil {
+			results[i].Err = err
+		}
+	}
+
+	return results
+}
+
+// Граница интервала корректности источника
+type edge struct {
+	offset time.Duration
+	start  bool
+}
+
+/*
+Выбирает лучший источник времени.
+Каждый валидный ответ задаёт интервал [offset - rootDistance, offset + rootDistance],
+в котором находится истинное время. Алгоритмом Марзулло ищется пересечение,
+общее для наибольшего числа источников. Если таких источников не большинство,
+серверы считаются несогласованными. Среди согласованных источников выбирается
+источник с наименьшим root distance, при равенстве - с наименьшим stratum.
+*/
+func SelectBest(results []ServerResult) (ServerResult, error) {
+	valid := make([]ServerResult, 0, len(results))
+	for _, result := range results {
+		if result.Err == nil && result.Response != nil {
+			valid = append(valid, result)
+		}
+	}
+
+	if len(valid) == 0 {
+		return ServerResult{}, ErrNoValidResponses
+	}
+
+	// Собираем границы интервалов всех источников
+	edges := make([]edge, 0, 2*len(valid))
+	for _, result := range valid {
+		low, high := interval(result.Response)
+		edges = append(edges, edge{offset: low, start: true}, edge{offset: high, start: false})
+	}
+
+	// Начало интервала идёт раньше конца при совпадении смещений,
+	// чтобы соприкасающиеся интервалы считались пересекающимися
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].offset == edges[j].offset {
+			return edges[i].start && !edges[j].start
+		}
+		return edges[i].offset < edges[j].offset
+	})
+
+	// Поиск точки, покрытой наибольшим числом интервалов
+	count, bestCount := 0, 0
+	var bestPoint time.Duration
+	for _, e := range edges {
+		if e.start {
+			count++
+			if count > bestCount {
+				bestCount = count
+				bestPoint = e.offset
+			}
+			continue
+		}
+		count--
+	}
+
+	// Источники должны быть согласованы в большинстве
+	if bestCount*2 <= len(valid) {
+		return ServerResult{}, ErrNoMajority
+	}
+
+	var best ServerResult
+	for _, result := range valid {
+		low, high := interval(result.Response)
+		if bestPoint < low || bestPoint > high {
+			continue
+		}
+
+		if best.Response == nil || better(result.Response, best.Response) {
+			best = result
+		}
+	}
+
+	return best, nil
+}
+
+// Интервал корректности источника
+func interval(r *ntp.Response) (time.Duration, time.Duration) {
+	return r.ClockOffset - r.RootDistance, r.ClockOffset + r.RootDistance
+}
+
+// Сравнивает два ответа по root distance и stratum
+func better(a, b *ntp.Response) bool {
+	if a.RootDistance != b.RootDistance {
+		return a.RootDistance < b.RootDistance
+	}
+
+	return a.Stratum < b.Stratum
+}
+
+// Строковое представление индикатора дополнительной секунды
+func leapString(leap ntp.LeapIndicator) string {
+	switch leap {
+	case ntp.LeapNoWarning:
+		return "none"
+	case ntp.LeapAddSecond:
+		return "add second"
+	case ntp.LeapDelSecond:
+		return "delete second"
+	case ntp.LeapNotInSync:
+		return "not in sync"
+	}
+
+	return "unknown"
+}
+
+// Печатает результаты опроса серверов и выбранный источник
+func printResults(w io.Writer, results []ServerResult, best ServerResult) {
+	for _, result := range results {
+		if result.Response == nil {
+			fmt.Fprintf(w, "%s: error: %v\n", result.Server, result.Err)
+			continue
+		}
+
+		r := result.Response
+		fmt.Fprintf(w, "%s: offset: %v, rtt: %v, stratum: %d, reference id: %s, leap: %s",
+			result.Server, r.ClockOffset, r.RTT, r.Stratum, r.ReferenceString(), leapString(r.Leap))
+		if result.Err != nil {
+			fmt.Fprintf(w, ", error: %v", result.Err)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "best source: %s\n", best.Server)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Начало эпохи NTP
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Размер заголовка NTP-пакета
+const packetSize = 48
+
+// Режимы NTP-пакета
+const (
+	modeClient = 3
+	modeServer = 4
+)
+
+// Максимальный stratum синхронизированного сервера
+const maxStratum = 15
+
+// Параметры, которые SNTP-сервер сообщает клиентам
+type ServerConfig struct {
+	Offset         time.Duration // поправка локальных часов
+	Stratum        uint8
+	ReferenceID    uint32
+	ReferenceTime  time.Time
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	Leap           ntp.LeapIndicator
+}
+
+/*
+Формирует параметры сервера по ответу вышестоящего сервера host.
+Stratum увеличивается на единицу, задержка до первичного источника
+включает задержку до вышестоящего сервера.
+*/
+func ServerConfigFromResponse(host string, r *ntp.Response) ServerConfig {
+	stratum := r.Stratum + 1
+	if stratum > maxStratum {
+		stratum = maxStratum
+	}
+
+	return ServerConfig{
+		Offset:         r.ClockOffset,
+		Stratum:        stratum,
+		ReferenceID:    referenceID(host),
+		ReferenceTime:  time.Now().Add(r.ClockOffset),
+		RootDelay:      r.RootDelay + r.RTT,
+		RootDispersion: r.RootDispersion,
+		Leap:           r.Leap,
+	}
+}
+
+/*
+Reference ID для stratum > 1: IPv4-адрес вышестоящего сервера,
+для IPv6 и неразрешимых имён - первые 4 байта MD5 от адреса.
+*/
+func referenceID(host string) uint32 {
+	if addr, err := net.ResolveUDPAddr("udp", ntpAddress(host)); err == nil {
+		if ip := addr.IP.To4(); ip != nil {
+			return binary.BigEndian.Uint32(ip)
+		}
+		host = addr.IP.String()
+	}
+
+	sum := md5.Sum([]byte(host))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// Добавляет стандартный порт NTP, если он не указан
+func ntpAddress(host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(host, "123")
+}
+
+// SNTPv4-сервер, отвечающий временем с поправкой от вышестоящего сервера
+type TimeServer struct {
+	conn net.PacketConn
+
+	mu  sync.RWMutex
+	cfg ServerConfig
+}
+
+// Создаёт сервер, принимающий запросы на conn
+func NewTimeServer(conn net.PacketConn, cfg ServerConfig) *TimeServer {
+	return &TimeServer{
+		conn: conn,
+		cfg:  cfg,
+	}
+}
+
+// Обновляет параметры сервера после повторного опроса вышестоящего сервера
+func (s *TimeServer) Update(cfg ServerConfig) {
+	s.mu.Lock()
+	s.cfg = cfg
+	s.mu.Unlock()
+}
+
+/*
+Обрабатывает запросы до закрытия соединения.
+Ошибка отправки ответа одному клиенту печатается в errOut,
+и сервер продолжает отвечать остальным. Работа завершается только
+при закрытии соединения или ошибке чтения.
+*/
+func (s *TimeServer) Serve(errOut io.Writer) error {
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.RLock()
+		cfg := s.cfg
+		s.mu.RUnlock()
+
+		received := time.Now().Add(cfg.Offset)
+		reply, ok := buildReply(buf[:n], cfg, received)
+		if !ok {
+			continue
+		}
+
+		if _, err := s.conn.WriteTo(reply, addr); err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			fmt.Fprintf(errOut, "reply to %v: %v\n", addr, err)
+		}
+	}
+}
+
+// Закрывает соединение сервера
+func (s *TimeServer) Close() error {
+	return s.conn.Close()
+}
+
+/*
+Формирует ответ на клиентский запрос request.
+Запросы короче заголовка и запросы не в режиме клиента игнорируются.
+*/
+func buildReply(request []byte, cfg ServerConfig, received time.Time) ([]byte, bool) {
+	if len(request) < packetSize || request[0]&0x07 != modeClient {
+		return nil, false
+	}
+
+	version := request[0] >> 3 & 0x07
+
+	reply := make([]byte, packetSize)
+	reply[0] = uint8(cfg.Leap)<<6 | version<<3 | modeServer
+	reply[1] = cfg.Stratum
+	// Интервал опроса передаём таким же, как у клиента
+	reply[2] = request[2]
+	// Точность часов - около микросекунды
+	reply[3] = 0xec
+	binary.BigEndian.PutUint32(reply[4:], ntpShort(cfg.RootDelay))
+	binary.BigEndian.PutUint32(reply[8:], ntpShort(cfg.RootDispersion))
+	binary.BigEndian.PutUint32(reply[12:], cfg.ReferenceID)
+	binary.BigEndian.PutUint64(reply[16:], ntpTime(cfg.ReferenceTime))
+	// Origin timestamp - transmit timestamp клиента
+	copy(reply[24:32], request[40:48])
+	binary.BigEndian.PutUint64(reply[32:], ntpTime(received))
+	binary.BigEndian.PutUint64(reply[40:], ntpTime(time.Now().Add(cfg.Offset)))
+
+	return reply, true
+}
+
+// Перевод времени в 64-битный формат NTP
+func ntpTime(t time.Time) uint64 {
+	d := t.Sub(ntpEpoch)
+	sec := uint64(d / time.Second)
+	frac := uint64(d%time.Second) << 32 / uint64(time.Second)
+	return sec<<32 | frac
+}
+
+// Перевод длительности в 32-битный формат NTP
+func ntpShort(d time.Duration) uint32 {
+	sec := uint32(d / time.Second)
+	frac := uint32(uint64(d%time.Second) << 16 / uint64(time.Second))
+	return sec<<16 | frac
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"net"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/beevik/ntp"
 )
@@ -20,20 +27,250 @@ import (
 // константа с адресом NTP-сервера
 const timeHost = "0.beevik-ntp.pool.ntp.org"
 
+type Flags struct {
+	Servers []string      // -servers список NTP-серверов через запятую
+	Timeout time.Duration // -timeout время ожидания ответа сервера
+
+	Auth ntp.AuthOptions // -keys и -key-id симметричный ключ аутентификации
+
+	Format   string         // -format формат вывода времени
+	Location *time.Location // -tz временная зона вывода
+
+	Monitor     bool          // -monitor режим мониторинга дрейфа часов
+	MinInterval time.Duration // -min-interval минимальный интервал опроса
+	MaxInterval time.Duration // -max-interval максимальный интервал опроса
+	MaxOffset   time.Duration // -max-offset порог смещения часов
+	MaxDrift    float64       // -max-drift порог дрейфа часов в ppm
+	History     int           // -history количество хранимых замеров
+
+	Serve        string        // -serve адрес SNTP-сервера, например :123
+	SyncInterval time.Duration // -sync-interval интервал повторного опроса вышестоящего сервера
+}
+
+// Параметры запроса к NTP-серверу
+func queryOptions(flg Flags) ntp.QueryOptions {
+	return ntp.QueryOptions{
+		Timeout: flg.Timeout,
+		Auth:    flg.Auth,
+	}
+}
+
+// Опрос списка серверов и выбор лучшего источника времени
+func querySources(flg Flags) {
+	results := QueryServers(flg.Servers, queryOptions(flg))
+
+	best, err := SelectBest(results)
+	if err != nil {
+		printResults(os.Stdout, results, ServerResult{Server: "none"})
+		fmt.Fprintf(os.Stderr, "%v", err)
+		// Если валидных ответов нет из-за аутентификации, сообщаем об этом отдельным кодом
+		if err == ErrNoValidResponses && authFailed(results) {
+			os.Exit(exitAuthFailed)
+		}
+		os.Exit(-1)
+	}
+
+	// Машиночитаемые форматы выводят только время лучшего источника
+	if flg.Format != FormatText {
+		printReport(NewReport(best.Server, best.Response), flg)
+		return
+	}
+
+	printResults(os.Stdout, results, best)
+}
+
+// Печатает отчёт о времени в выбранном формате
+func printReport(report Report, flg Flags) {
+	if err := WriteReport(os.Stdout, report, flg.Format, flg.Location); err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(-1)
+	}
+}
+
+// Мониторинг дрейфа часов до получения сигнала завершения
+func monitor(flg Flags) {
+	server := timeHost
+	if len(flg.Servers) > 0 {
+		server = flg.Servers[0]
+	}
+
+	m := NewMonitor(MonitorConfig{
+		Server:      server,
+		Timeout:     flg.Timeout,
+		Auth:        flg.Auth,
+		MinInterval: flg.MinInterval,
+		MaxInterval: flg.MaxInterval,
+		MaxOffset:   flg.MaxOffset,
+		MaxDrift:    flg.MaxDrift,
+		History:     flg.History,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	status := m.Run(ctx, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(status)
+}
+
+// Опрашивает вышестоящие серверы и формирует параметры SNTP-сервера
+func upstreamConfig(flg Flags) (ServerConfig, error) {
+	servers := flg.Servers
+	if len(servers) == 0 {
+		servers = []string{timeHost}
+	}
+
+	best, err := SelectBest(QueryServers(servers, queryOptions(flg)))
+	if err != nil {
+		return ServerConfig{}, err
+	}
+
+	return ServerConfigFromResponse(best.Server, best.Response), nil
+}
+
+// Раздача времени по SNTP с периодической синхронизацией с вышестоящим сервером
+func serve(flg Flags) {
+	cfg, err := upstreamConfig(flg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(-1)
+	}
+
+	conn, err := net.ListenPacket("udp", flg.Serve)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(-1)
+	}
+
+	server := NewTimeServer(conn, cfg)
+
+	go func() {
+		for range time.Tick(flg.SyncInterval) {
+			// При ошибке продолжаем раздавать время с прежней поправкой
+			cfg, err := upstreamConfig(flg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				continue
+			}
+			server.Update(cfg)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	err = server.Serve(os.Stderr)
+	stop()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(-1)
+	}
+}
+
+// Парсит аргументы командной строки
+func parseFlags() Flags {
+	servers := flag.String("servers", "", "comma-separated list of ntp servers to query")
+	timeout := flag.Duration("timeout", 5*time.Second, "ntp server response timeout")
+	monitor := flag.Bool("monitor", false, "poll ntp server and monitor clock drift")
+	minInterval := flag.Duration("min-interval", 16*time.Second, "minimal poll interval in monitor mode")
+	maxInterval := flag.Duration("max-interval", 1024*time.Second, "maximal poll interval in monitor mode")
+	maxOffset := flag.Duration("max-offset", 100*time.Millisecond, "clock offset alert threshold, 0 to disable")
+	maxDrift := flag.Float64("max-drift", 50, "clock drift alert threshold in ppm, 0 to disable")
+	history := flag.Int("history", 32, "number of offset samples kept for drift estimation")
+	format := flag.String("format", FormatText, "output format: text, rfc3339, rfc3339nano, unix, unix-ms, unix-ns, json or layout:<go time layout>")
+	tz := flag.String("tz", "Local", "IANA time zone of the printed time")
+	serveAddr := flag.String("serve", "", "udp address to serve sntp on, e.g. :123")
+	syncInterval := flag.Duration("sync-interval", 1024*time.Second, "upstream poll interval in server mode")
+	keys := flag.String("keys", "", "symmetric key file in ntp.keys format")
+	keyID := flag.Uint("key-id", 0, "id of the key from the key file, 0 for the first key")
+
+	flag.Parse()
+
+	if err := ValidateFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(-1)
+	}
+
+	location, err := time.LoadLocation(*tz)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(-1)
+	}
+
+	var auth ntp.AuthOptions
+	if *keys != "" {
+		auth, err = LoadKey(*keys, uint16(*keyID))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(-1)
+		}
+	}
+
+	flg := Flags{
+		Timeout:     *timeout,
+		Auth:        auth,
+		Format:      *format,
+		Location:    location,
+		Monitor:     *monitor,
+		MinInterval: *minInterval,
+		MaxInterval: *maxInterval,
+		MaxOffset:   *maxOffset,
+		MaxDrift:    *maxDrift,
+		History:     *history,
+
+		Serve:        *serveAddr,
+		SyncInterval: *syncInterval,
+	}
+
+	for _, server := range strings.Split(*servers, ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			flg.Servers = append(flg.Servers, server)
+		}
+	}
+
+	return flg
+}
+
 func main() {
+	flg := parseFlags()
+
+	// В режиме сервера раздаём время до завершения программы
+	if flg.Serve != "" {
+		serve(flg)
+		return
+	}
+
+	// В режиме мониторинга опрашиваем сервер до завершения программы
+	if flg.Monitor {
+		monitor(flg)
+		return
+	}
+
+	// Если задан список серверов, опрашиваем их все
+	if len(flg.Servers) > 0 {
+		querySources(flg)
+		return
+	}
+
 	// Получение текущего времени с помощью NTP-сервера
-	time, err := ntp.Time(timeHost)
+	response, err := ntp.QueryWithOptions(timeHost, queryOptions(flg))
+	if err == nil {
+		err = response.Validate()
+	}
 	if err != nil {
 		/*
 			В случае ошибки выводим сообщение об ошибке
-			в стандартный поток ошибок и завершаем программу с кодом -1
+			в стандартный поток ошибок и завершаем программу с кодом -1,
+			при ошибке аутентификации ответа - с кодом exitAuthFailed
 		*/
 		fmt.Fprintf(os.Stderr, "%v", err)
+		if err == ntp.ErrAuthFailed {
+			os.Exit(exitAuthFailed)
+		}
 		os.Exit(-1)
 	}
 
-	// Вывод текущего времени в стандартный поток вывода
-	fmt.Fprintf(os.Stdout, "current time: %s\n", time.Format("15:04:05"))
-	// Вывод точного времени (с миллисекундами и наносекундами) в стандартный поток вывода
-	fmt.Fprintf(os.Stdout, "exact time: %s", time.Format("15:04:05.000000000"))
+	// Вывод времени в стандартный поток вывода в выбранном формате
+	printReport(NewReport(timeHost, response), flg)
 }
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Параметры локального NTP-сервера для тестов
+type fakeServer struct {
+	offset      time.Duration
+	stratum     uint8
+	rootDelay   time.Duration
+	rootDisp    time.Duration
+	referenceID uint32
+	leap        ntp.LeapIndicator
+
+	// Подпись ответа ключом SHA256, при keyID = 0 ответ не подписывается
+	keyID uint32
+	key   string
+}
+
+// Запускает локальный UDP NTP-сервер и возвращает его адрес
+func startFakeServer(t *testing.T, fs fakeServer) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 48 {
+				continue
+			}
+
+			now := time.Now().Add(fs.offset)
+			resp := make([]byte, 48)
+			resp[0] = uint8(fs.leap)<<6 | 4<<3 | 4
+			resp[1] = fs.stratum
+			resp[2] = 6
+			resp[3] = 0xec
+			binary.BigEndian.PutUint32(resp[4:], ntpShort(fs.rootDelay))
+			binary.BigEndian.PutUint32(resp[8:], ntpShort(fs.rootDisp))
+			binary.BigEndian.PutUint32(resp[12:], fs.referenceID)
+			binary.BigEndian.PutUint64(resp[16:], ntpTime(now.Add(-time.Second)))
+			copy(resp[24:32], buf[40:48])
+			binary.BigEndian.PutUint64(resp[32:], ntpTime(now))
+			binary.BigEndian.PutUint64(resp[40:], ntpTime(now))
+
+			if fs.keyID != 0 {
+				digest := sha256.Sum256(append([]byte(fs.key), resp...))
+				resp = binary.BigEndian.AppendUint32(resp, fs.keyID)
+				resp = append(resp, digest[:20]...)
+			}
+
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestQueryServers(t *testing.T) {
+	addr := startFakeServer(t, fakeServer{
+		offset:      2 * time.Second,
+		stratum:     2,
+		rootDelay:   10 * time.Millisecond,
+		rootDisp:    5 * time.Millisecond,
+		referenceID: 0x7f000001,
+		leap:        ntp.LeapAddSecond,
+	})
+
+	results := QueryServers([]string{addr}, ntp.QueryOptions{Timeout: time.Second})
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+
+	result := results[0]
+	if result.Err != nil {
+		t.Fatalf("unexpected error: %v", result.Err)
+	}
+
+	r := result.Response
+	if r.Stratum != 2 {
+		t.Errorf("expected stratum 2, got %d", r.Stratum)
+	}
+	if r.ReferenceString() != "127.0.0.1" {
+		t.Errorf("expected reference id 127.0.0.1, got %s", r.ReferenceString())
+	}
+	if r.Leap != ntp.LeapAddSecond {
+		t.Errorf("expected leap %d, got %d", ntp.LeapAddSecond, r.Leap)
+	}
+	if diff := r.ClockOffset - 2*time.Second; diff < -100*time.Millisecond || diff > 100*time.Millisecond {
+		t.Errorf("expected offset about 2s, got %v", r.ClockOffset)
+	}
+}
+
+func TestSelectBest(t *testing.T) {
+	good := startFakeServer(t, fakeServer{
+		offset:    10 * time.Millisecond,
+		stratum:   1,
+		rootDelay: 2 * time.Millisecond,
+		rootDisp:  time.Millisecond,
+	})
+	worse := startFakeServer(t, fakeServer{
+		offset:    15 * time.Millisecond,
+		stratum:   3,
+		rootDelay: 80 * time.Millisecond,
+		rootDisp:  20 * time.Millisecond,
+	})
+	falseticker := startFakeServer(t, fakeServer{
+		offset:    time.Hour,
+		stratum:   1,
+		rootDelay: time.Millisecond,
+		rootDisp:  time.Millisecond,
+	})
+	unsynced := startFakeServer(t, fakeServer{
+		stratum: 2,
+		leap:    ntp.LeapNotInSync,
+	})
+
+	results := QueryServers([]string{worse, falseticker, good, unsynced}, ntp.QueryOptions{Timeout: time.Second})
+
+	best, err := SelectBest(results)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if best.Server != good {
+		t.Errorf("expected %s, got %s", good, best.Server)
+	}
+
+	if results[3].Err != ntp.ErrInvalidLeapSecond {
+		t.Errorf("expected %v, got %v", ntp.ErrInvalidLeapSecond, results[3].Err)
+	}
+}
+
+func TestSelectBestNoMajority(t *testing.T) {
+	first := startFakeServer(t, fakeServer{
+		offset:   0,
+		stratum:  1,
+		rootDisp: time.Millisecond,
+	})
+	second := startFakeServer(t, fakeServer{
+		offset:   time.Hour,
+		stratum:  1,
+		rootDisp: time.Millisecond,
+	})
+
+	results := QueryServers([]string{first, second}, ntp.QueryOptions{Timeout: time.Second})
+
+	if _, err := SelectBest(results); err != ErrNoMajority {
+		t.Errorf("expected %v, got %v", ErrNoMajority, err)
+	}
+
+	if _, err := SelectBest(nil); err != ErrNoValidResponses {
+		t.Errorf("expected %v, got %v", ErrNoValidResponses, err)
+	}
+}
+
+func TestMonitorDrift(t *testing.T) {
+	m := NewMonitor(MonitorConfig{History: 10})
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		// Смещение растёт на 100 мкс в секунду, то есть дрейф 100 ppm
+		m.history = append(m.history, Sample{
+			Time:   start.Add(time.Duration(i) * time.Second),
+			Offset: time.Duration(i) * 100 * time.Microsecond,
+		})
+	}
+
+	drift, ok := m.Drift()
+	if !ok {
+		t.Fatal("expected drift estimate")
+	}
+	if drift < 99.9 || drift > 100.1 {
+		t.Errorf("expected drift about 100 ppm, got %f", drift)
+	}
+
+	if _, ok := NewMonitor(MonitorConfig{}).Drift(); ok {
+		t.Error("expected no drift estimate without samples")
+	}
+}
+
+func TestMonitorPoll(t *testing.T) {
+	good := startFakeServer(t, fakeServer{
+		offset:   10 * time.Millisecond,
+		stratum:  2,
+		rootDisp: time.Millisecond,
+	})
+	bad := startFakeServer(t, fakeServer{
+		offset:   time.Second,
+		stratum:  2,
+		rootDisp: time.Millisecond,
+	})
+
+	cfg := MonitorConfig{
+		Server:      good,
+		Timeout:     time.Second,
+		MinInterval: time.Second,
+		MaxInterval: 4 * time.Second,
+		MaxOffset:   100 * time.Millisecond,
+		History:     4,
+	}
+
+	var out, errOut strings.Builder
+	m := NewMonitor(cfg)
+	for i := 0; i < 3; i++ {
+		m.Poll(&out, &errOut)
+	}
+
+	if m.Status() != HealthOK {
+		t.Errorf("expected status %d, got %d, output: %s", HealthOK, m.Status(), out.String())
+	}
+	if m.Interval() != 4*time.Second {
+		t.Errorf("expected interval 4s, got %v", m.Interval())
+	}
+
+	cfg.Server = bad
+	m = NewMonitor(cfg)
+	m.Poll(&out, &errOut)
+	m.Poll(&out, &errOut)
+
+	if m.Status() != HealthAlert {
+		t.Errorf("expected status %d, got %d", HealthAlert, m.Status())
+	}
+	if m.Interval() != time.Second {
+		t.Errorf("expected interval 1s, got %v", m.Interval())
+	}
+	if !strings.Contains(out.String(), "alert: offset") {
+		t.Errorf("expected alert line, got %s", out.String())
+	}
+}
+
+func TestMonitorMinInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second, time.Millisecond} {
+		m := NewMonitor(MonitorConfig{MinInterval: interval})
+		if m.Interval() != minPollInterval {
+			t.Errorf("min interval %v: expected %v, got %v", interval, minPollInterval, m.Interval())
+		}
+
+		// Удвоение нулевого интервала оставило бы его нулевым
+		m.grow()
+		if m.Interval() != minPollInterval {
+			t.Errorf("min interval %v: expected %v after grow, got %v", interval, minPollInterval, m.Interval())
+		}
+	}
+}
+
+func TestMonitorBackoff(t *testing.T) {
+	m := NewMonitor(MonitorConfig{
+		Server:      "unreachable",
+		MinInterval: time.Second,
+		MaxInterval: 3 * time.Second,
+	})
+	m.query = func(string, ntp.QueryOptions) (*ntp.Response, error) {
+		return nil, errors.New("timeout")
+	}
+
+	var out, errOut strings.Builder
+	m.Poll(&out, &errOut)
+	if m.Interval() != 2*time.Second {
+		t.Errorf("expected interval 2s, got %v", m.Interval())
+	}
+	m.Poll(&out, &errOut)
+	if m.Interval() != 3*time.Second {
+		t.Errorf("expected interval 3s, got %v", m.Interval())
+	}
+
+	if m.Status() != HealthError {
+		t.Errorf("expected status %d, got %d", HealthError, m.Status())
+	}
+	if errOut.String() != "timeout\ntimeout\n" {
+		t.Errorf("unexpected stderr output: %q", errOut.String())
+	}
+}
+
+// Запускает SNTP-сервер на локальном адресе и возвращает его адрес
+func startTimeServer(t *testing.T, cfg ServerConfig) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := NewTimeServer(conn, cfg)
+	t.Cleanup(func() { server.Close() })
+
+	go server.Serve(io.Discard)
+
+	return conn.LocalAddr().String()
+}
+
+func TestTimeServer(t *testing.T) {
+	upstream := startFakeServer(t, fakeServer{
+		offset:    3 * time.Second,
+		stratum:   2,
+		rootDelay: 10 * time.Millisecond,
+		rootDisp:  5 * time.Millisecond,
+	})
+
+	results := QueryServers([]string{upstream}, ntp.QueryOptions{Timeout: time.Second})
+	if results[0].Err != nil {
+		t.Fatalf("unexpected error: %v", results[0].Err)
+	}
+
+	addr := startTimeServer(t, ServerConfigFromResponse(upstream, results[0].Response))
+
+	results = QueryServers([]string{addr}, ntp.QueryOptions{Timeout: time.Second})
+	result := results[0]
+	if result.Err != nil {
+		t.Fatalf("unexpected error: %v", result.Err)
+	}
+
+	r := result.Response
+	if r.Stratum != 3 {
+		t.Errorf("expected stratum 3, got %d", r.Stratum)
+	}
+	if r.ReferenceString() != "127.0.0.1" {
+		t.Errorf("expected reference id 127.0.0.1, got %s", r.ReferenceString())
+	}
+	// ntpShort отбрасывает доли меньше 1/65536 секунды при ответе upstream и при нашем ответе
+	if minDelay := 10*time.Millisecond - 2*(time.Second>>16); r.RootDelay < minDelay {
+		t.Errorf("expected root delay at least %v, got %v", minDelay, r.RootDelay)
+	}
+	if diff := r.ClockOffset - 3*time.Second; diff < -100*time.Millisecond || diff > 100*time.Millisecond {
+		t.Errorf("expected offset about 3s, got %v", r.ClockOffset)
+	}
+}
+
+func TestTimeServerUpdate(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := NewTimeServer(conn, ServerConfig{Stratum: 2, ReferenceTime: time.Now()})
+	t.Cleanup(func() { server.Close() })
+	go server.Serve(io.Discard)
+
+	server.Update(ServerConfig{Stratum: 4, Offset: -time.Minute, ReferenceTime: time.Now()})
+
+	response, err := ntp.QueryWithOptions(conn.LocalAddr().String(), ntp.QueryOptions{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if response.Stratum != 4 {
+		t.Errorf("expected stratum 4, got %d", response.Stratum)
+	}
+	if diff := response.ClockOffset + time.Minute; diff < -100*time.Millisecond || diff > 100*time.Millisecond {
+		t.Errorf("expected offset about -1m, got %v", response.ClockOffset)
+	}
+}
+
+// Соединение, первая отправка через которое завершается ошибкой
+type failingConn struct {
+	net.PacketConn
+	failed bool
+}
+
+func (c *failingConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if !c.failed {
+		c.failed = true
+		return 0, errors.New("network is unreachable")
+	}
+
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func TestTimeServerWriteError(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := NewTimeServer(&failingConn{PacketConn: conn}, ServerConfig{Stratum: 2, ReferenceTime: time.Now()})
+
+	var errOut strings.Builder
+	done := make(chan error)
+	go func() { done <- server.Serve(&errOut) }()
+
+	// Первый ответ не отправляется, клиент не дождётся его
+	addr := conn.LocalAddr().String()
+	if _, err := ntp.QueryWithOptions(addr, ntp.QueryOptions{Timeout: 100 * time.Millisecond}); err == nil {
+		t.Error("expected first query to time out")
+	}
+
+	if _, err := ntp.QueryWithOptions(addr, ntp.QueryOptions{Timeout: time.Second}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	server.Close()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error from Serve: %v", err)
+	}
+	if !strings.Contains(errOut.String(), "network is unreachable") {
+		t.Errorf("expected write error in output, got %q", errOut.String())
+	}
+}
+
+func TestBuildReplyIgnoresNonClient(t *testing.T) {
+	request := make([]byte, packetSize)
+	// Пакет в режиме сервера
+	request[0] = 4<<3 | modeServer
+
+	if _, ok := buildReply(request, ServerConfig{}, time.Now()); ok {
+		t.Error("expected server mode packet to be ignored")
+	}
+	if _, ok := buildReply(request[:10], ServerConfig{}, time.Now()); ok {
+		t.Error("expected short packet to be ignored")
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("time zone database is unavailable: %v", err)
+	}
+
+	report := Report{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		Offset:  1500 * time.Microsecond,
+		RTT:     20 * time.Millisecond,
+		Server:  "127.0.0.1:123",
+		Stratum: 2,
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		loc      *time.Location
+		expected string
+	}{
+		{
+			name:     "text",
+			format:   FormatText,
+			loc:      time.UTC,
+			expected: "current time: 03:04:05\nexact time: 03:04:05.123456789",
+		},
+		{
+			name:     "rfc3339",
+			format:   FormatRFC3339,
+			loc:      moscow,
+			expected: "2024-01-02T06:04:05+03:00\n",
+		},
+		{
+			name:     "rfc3339nano",
+			format:   FormatRFC3339Nano,
+			loc:      moscow,
+			expected: "2024-01-02T06:04:05.123456789+03:00\n",
+		},
+		{
+			name:     "unix",
+			format:   FormatUnix,
+			loc:      time.UTC,
+			expected: "1704164645\n",
+		},
+		{
+			name:     "unix-ms",
+			format:   FormatUnixMilli,
+			loc:      time.UTC,
+			expected: "1704164645123\n",
+		},
+		{
+			name:     "unix-ns",
+			format:   FormatUnixNano,
+			loc:      time.UTC,
+			expected: "1704164645123456789\n",
+		},
+		{
+			name:   "json",
+			format: FormatJSON,
+			loc:    moscow,
+			expected: `{"time":"2024-01-02T06:04:05.123456789+03:00","offset":1500000,"rtt":20000000,` +
+				`"server":"127.0.0.1:123","stratum":2}` + "\n",
+		},
+		{
+			name:     "layout",
+			format:   "layout:2006/01/02 15:04 MST",
+			loc:      moscow,
+			expected: "2024/01/02 06:04 MSK\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := WriteReport(&out, report, test.format, test.loc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestValidateFormat(t *testing.T) {
+	for _, format := range []string{FormatJSON, FormatRFC3339, FormatUnixNano, "layout:15:04", "layout:01/02"} {
+		if err := ValidateFormat(format); err != nil {
+			t.Errorf("unexpected error for %q: %v", format, err)
+		}
+	}
+
+	for _, format := range []string{"", "jsn", "plain text", "rfc4449", "15:04", "layout:", "layout:plain"} {
+		if err := ValidateFormat(format); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("expected %v for %q, got %v", ErrUnknownFormat, format, err)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	input := `
+# id type key
+1 SHA256 ASCII:secret
+2 md5 HEX:6376755a794e3443 # comment
+`
+
+	keys, err := ParseKeys(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ntp.AuthOptions{
+		{Type: ntp.AuthSHA256, Key: "ASCII:secret", KeyID: 1},
+		{Type: ntp.AuthMD5, Key: "HEX:6376755a794e3443", KeyID: 2},
+	}
+	if len(keys) != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), len(keys))
+	}
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], keys[i])
+		}
+	}
+
+	for _, input := range []string{"1 SHA256", "x SHA256 secret", "1 CRC32 secret", "0 MD5 secret"} {
+		if _, err := ParseKeys(strings.NewReader(input)); !errors.Is(err, ErrInvalidKeyFile) {
+			t.Errorf("expected %v for %q, got %v", ErrInvalidKeyFile, input, err)
+		}
+	}
+}
+
+func TestAuthenticatedQuery(t *testing.T) {
+	auth := ntp.AuthOptions{Type: ntp.AuthSHA256, Key: "ASCII:secret-key", KeyID: 7}
+
+	signed := startFakeServer(t, fakeServer{stratum: 2, keyID: 7, key: "secret-key"})
+	wrongKey := startFakeServer(t, fakeServer{stratum: 2, keyID: 7, key: "other-key"})
+	wrongID := startFakeServer(t, fakeServer{stratum: 2, keyID: 8, key: "secret-key"})
+	unsigned := startFakeServer(t, fakeServer{stratum: 2})
+
+	results := QueryServers([]string{signed, wrongKey, wrongID, unsigned}, ntp.QueryOptions{Timeout: time.Second, Auth: auth})
+
+	if results[0].Err != nil {
+		t.Errorf("unexpected error: %v", results[0].Err)
+	}
+	for _, result := range results[1:] {
+		if result.Err != ntp.ErrAuthFailed {
+			t.Errorf("expected %v for %s, got %v", ntp.ErrAuthFailed, result.Server, result.Err)
+		}
+	}
+
+	if !authFailed(results) {
+		t.Error("expected authentication failure to be reported")
+	}
+
+	best, err := SelectBest(results[1:])
+	if err != ErrNoValidResponses {
+		t.Errorf("expected %v, got %v (%s)", ErrNoValidResponses, err, best.Server)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import "fmt"
+
+// Причина ошибки распаковки
+type Reason int
+
+const (
+	ReasonLeadingDigit   Reason = iota + 1 // цифра без символа для повторения
+	ReasonDanglingEscape                   // экранирующий символ в конце строки
+	ReasonUnclosedGroup                    // группа без закрывающей скобки
+	ReasonUnmatchedGroup                   // закрывающая скобка без открывающей
+	ReasonEmptyGroup                       // пустая группа
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonLeadingDigit:
+		return "digit without preceding character"
+	case ReasonDanglingEscape:
+		return "escape at end of string"
+	case ReasonUnclosedGroup:
+		return "unclosed group"
+	case ReasonUnmatchedGroup:
+		return "unmatched group end"
+	case ReasonEmptyGroup:
+		return "empty group"
+	}
+
+	return "unknown reason"
+}
+
+/*
+Ошибка распаковки некорректной строки с позицией проблемного символа.
+Удовлетворяет errors.Is(err, ErrInvalidString).
+*/
+type UnpackError struct {
+	Reason     Reason
+	Rune       rune // проблемный символ
+	RuneOffset int  // номер символа в строке, начиная с 0
+	ByteOffset int  // смещение символа в байтах
+}
+
+func (e *UnpackError) Error() string {
+	return fmt.Sprintf("%v: %v: %q at rune %d (byte %d)", ErrInvalidString, e.Reason, e.Rune, e.RuneOffset, e.ByteOffset)
+}
+
+func (e *UnpackError) Unwrap() error {
+	return ErrInvalidString
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Ошибка некорректных параметров распаковки
+var ErrInvalidOptions = errors.New("invalid unpack options")
+
+// Режим обработки некорректных конструкций
+type Mode int
+
+const (
+	// Строгий режим: некорректная строка приводит к ошибке ErrInvalidString
+	ModeStrict Mode = iota
+	/*
+		Нестрогий режим: цифры без символа для повторения и обратный слэш
+		в конце строки записываются как есть, незакрытая группа закрывается
+		в конце строки, лишняя закрывающая скобка записывается как есть.
+	*/
+	ModeLenient
+)
+
+// Параметры грамматики распаковки. Нулевое значение соответствует Unpack
+type Options struct {
+	Escape    rune  // экранирующий символ, по умолчанию '\'
+	Groups    bool  // повторение групп в скобках: (ab)3 => ababab
+	Mode      Mode  // режим обработки некорректных конструкций
+	Graphemes bool  // повторять расширенный графемный кластер (UAX #29), а не последнюю руну
+	MaxOutput int64 // максимальный размер результата в байтах, 0 - без ограничения
+}
+
+// Экранирующий символ с учётом значения по умолчанию
+func (opt Options) escape() rune {
+	if opt.Escape == 0 {
+		return '\\'
+	}
+
+	return opt.Escape
+}
+
+// Проверяет, что экранирующий символ не совпадает со служебными символами грамматики
+func (opt Options) validate() error {
+	escape := opt.escape()
+	if isDigit(escape) {
+		return fmt.Errorf("%w: escape %q is a digit", ErrInvalidOptions, escape)
+	}
+	if opt.Groups && (escape == '(' || escape == ')') {
+		return fmt.Errorf("%w: escape %q is a group bracket", ErrInvalidOptions, escape)
+	}
+
+	return nil
+}
+
+/*
+Распаковывает строку целиком по грамматике opt.
+Результат хранится в памяти, для недоверенного ввода следует задать MaxOutput.
+*/
+func UnpackWithOptions(str string, opt Options) (string, error) {
+	builder := strings.Builder{}
+
+	if _, err := io.Copy(&builder, NewUnpackReader(strings.NewReader(str), opt)); err != nil {
+		return "", err
+	}
+
+	return builder.String(), nil
+}
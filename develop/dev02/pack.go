@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+Упаковывает строку в кратчайшую строку того же формата, что принимает Unpack.
+Цифры и обратный слэш экранируются. Серия одинаковых символов записывается
+символом с числом повторений, если это не длиннее, чем записать серию как есть
+(при равной длине как в примере "a4bc2d5e").
+Для любой строки в UTF-8 выполняется Unpack(Pack(s)) == s.
+*/
+func Pack(str string) string {
+	// strings.Builder для формирования строки(поэлементного)
+	builder := strings.Builder{}
+
+	runes := []rune(str)
+	for i := 0; i < len(runes); {
+		// Определяем длину серии одинаковых символов
+		j := i + 1
+		for j < len(runes) && runes[j] == runes[i] {
+			j++
+		}
+
+		writeRun(&builder, runes[i], j-i)
+		i = j
+	}
+
+	return builder.String()
+}
+
+// Записывает серию из count символов r кратчайшим способом
+func writeRun(builder *strings.Builder, r rune, count int) {
+	unit := string(r)
+	// Цифры и обратный слэш иначе будут разобраны как число повторений или экранирование
+	if isDigit(r) || r == '\\' {
+		unit = `\` + unit
+	}
+
+	repeat := strconv.Itoa(count)
+	if count > 1 && len(unit)+len(repeat) <= len(unit)*count {
+		builder.WriteString(unit)
+		builder.WriteString(repeat)
+		return
+	}
+
+	for i := 0; i < count; i++ {
+		builder.WriteString(unit)
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/rivo/uniseg"
+)
+
+// Ошибка превышения максимального размера распакованной строки
+var ErrOutputTooLarge = errors.New("unpacked output too large")
+
+// Признак закрывающей скобки группы при разборе содержимого группы
+var errGroupEnd = errors.New("end of group")
+
+/*
+Повторяемая единица: символ или группа единиц, записываемая count раз.
+Группа хранится в разобранном виде и не разворачивается в память,
+поэтому её размер зависит от длины входной строки, а не от числа повторений.
+*/
+type piece struct {
+	text  string  // символ, у группы пусто
+	parts []piece // содержимое группы
+	count int64
+	size  int64 // размер одного повтора в байтах
+}
+
+// Позиция вывода внутри единицы
+type cursor struct {
+	piece  *piece
+	repeat int64 // сколько повторов осталось записать, включая текущий
+	part   int   // номер следующей части группы в текущем повторе
+}
+
+/*
+Потоковый распаковщик строки.
+Повторяемые символы и группы хранятся вместе с оставшимся числом повторов,
+поэтому память не зависит от числа повторений.
+*/
+type unpackReader struct {
+	src     *bufio.Reader
+	opt     Options
+	limit   int64 // максимальный размер результата в байтах, 0 - без ограничения
+	written int64
+
+	// Позиция следующего символа входной строки
+	runeOffset int
+	byteOffset int
+	lastSize   int // размер последнего прочитанного символа для UnreadRune
+
+	stack   []cursor // выводимая единица и вложенные в неё группы
+	pending string   // часть символа, не поместившаяся в буфер Read
+
+	err error
+}
+
+// Возвращает Reader, лениво распаковывающий строку из r
+func UnpackReader(r io.Reader) io.Reader {
+	return UnpackReaderLimit(r, 0)
+}
+
+/*
+Возвращает Reader, лениво распаковывающий строку из r.
+Если результат превысит limit байт, чтение завершается ошибкой ErrOutputTooLarge.
+Как и Options.MaxOutput, limit = 0 означает отсутствие ограничения.
+Данные, прочитанные до ошибки, уже отданы вызывающему.
+*/
+func UnpackReaderLimit(r io.Reader, limit int64) io.Reader {
+	return &unpackReader{
+		src:   bufio.NewReader(r),
+		opt:   Options{Escape: '\\'},
+		limit: limit,
+	}
+}
+
+// Возвращает Reader, лениво распаковывающий строку из r по грамматике opt
+func NewUnpackReader(r io.Reader, opt Options) io.Reader {
+	opt.Escape = opt.escape()
+
+	u := &unpackReader{
+		src:   bufio.NewReader(r),
+		opt:   opt,
+		limit: opt.MaxOutput,
+	}
+	u.err = opt.validate()
+
+	return u
+}
+
+func (u *unpackReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		// Дописываем остаток повторяемого символа с прошлого вызова
+		if u.pending != "" {
+			c := copy(p[n:], u.pending)
+			u.pending = u.pending[c:]
+			n += c
+			continue
+		}
+
+		// Выводим следующий символ текущей единицы
+		if len(u.stack) > 0 {
+			u.pending = u.step()
+			continue
+		}
+
+		if u.err != nil {
+			break
+		}
+
+		u.err = u.next()
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+
+	return 0, u.err
+}
+
+/*
+Продвигает вывод текущей единицы и возвращает следующий символ
+или пустую строку, если на этом шаге закончился повтор или началась вложенная группа.
+*/
+func (u *unpackReader) step() string {
+	top := &u.stack[len(u.stack)-1]
+
+	switch {
+	case top.repeat == 0:
+		u.stack = u.stack[:len(u.stack)-1]
+		return ""
+
+	case top.piece.parts == nil:
+		top.repeat--
+		return top.piece.text
+
+	case top.part == len(top.piece.parts):
+		top.repeat--
+		top.part = 0
+		return ""
+	}
+
+	part := &top.piece.parts[top.part]
+	top.part++
+	u.stack = append(u.stack, cursor{piece: part, repeat: part.count})
+
+	return ""
+}
+
+// Разбирает следующую лексему входной строки: символ или группу с числом повторений
+func (u *unpackReader) next() error {
+	unit, err := u.readUnit(false)
+	if err != nil {
+		return err
+	}
+
+	unit.count, err = u.readRepeat()
+	if err != nil {
+		return err
+	}
+
+	return u.emit(unit)
+}
+
+// Создаёт единицу из символа или экранированной последовательности
+func textPiece(text string) piece {
+	return piece{text: text, size: int64(len(text))}
+}
+
+/*
+Считывает повторяемую единицу: символ, экранированный символ или группу.
+Внутри группы закрывающая скобка возвращается как errGroupEnd.
+*/
+func (u *unpackReader) readUnit(inGroup bool) (piece, error) {
+	runeOffset, byteOffset := u.runeOffset, u.byteOffset
+
+	r, err := u.readRune()
+	if err != nil {
+		return piece{}, err
+	}
+
+	switch {
+	// Если текущий символ цифра, то перед ней нет символа для повторения
+	case isDigit(r):
+		if u.opt.Mode == ModeLenient {
+			digits, err := u.readDigits(r)
+			return textPiece(digits), err
+		}
+		return piece{}, &UnpackError{
+			Reason:     ReasonLeadingDigit,
+			Rune:       r,
+			RuneOffset: runeOffset,
+			ByteOffset: byteOffset,
+		}
+
+	// Если текущий символ экранирующий, то следующий символ записываем как есть
+	case r == u.opt.Escape:
+		escaped, err := u.readRune()
+		if err == io.EOF {
+			// В случае, если экранирующий символ находится в конце строки
+			if u.opt.Mode == ModeLenient {
+				return textPiece(string(r)), nil
+			}
+			return piece{}, &UnpackError{
+				Reason:     ReasonDanglingEscape,
+				Rune:       r,
+				RuneOffset: runeOffset,
+				ByteOffset: byteOffset,
+			}
+		}
+		if err != nil {
+			return piece{}, err
+		}
+		return u.readCluster(string(escaped))
+
+	case u.opt.Groups && r == '(':
+		return u.readGroup(r, runeOffset, byteOffset)
+
+	case u.opt.Groups && r == ')':
+		if inGroup {
+			return piece{}, errGroupEnd
+		}
+		if u.opt.Mode == ModeLenient {
+			return textPiece(string(r)), nil
+		}
+		return piece{}, &UnpackError{
+			Reason:     ReasonUnmatchedGroup,
+			Rune:       r,
+			RuneOffset: runeOffset,
+			ByteOffset: byteOffset,
+		}
+
+	default:
+		return u.readCluster(string(r))
+	}
+}
+
+/*
+В режиме графемных кластеров дописывает к unit следующие символы,
+пока они продолжают тот же кластер: комбинируемые знаки, модификаторы
+и ZWJ-последовательности эмодзи, вторую половину флага.
+Служебные символы грамматики кластер не продолжают.
+*/
+func (u *unpackReader) extendCluster(unit string) (string, error) {
+	if !u.opt.Graphemes {
+		return unit, nil
+	}
+
+	for {
+		r, err := u.readRune()
+		if err == io.EOF {
+			return unit, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if u.isSyntax(r) || !continuesCluster(unit, r) {
+			return unit, u.unreadRune()
+		}
+		unit += string(r)
+	}
+}
+
+// Дочитывает графемный кластер, начинающийся с unit, и создаёт из него единицу
+func (u *unpackReader) readCluster(unit string) (piece, error) {
+	cluster, err := u.extendCluster(unit)
+	if err != nil {
+		return piece{}, err
+	}
+
+	return textPiece(cluster), nil
+}
+
+// Проверяет, является ли символ служебным в текущей грамматике
+func (u *unpackReader) isSyntax(r rune) bool {
+	return isDigit(r) || r == u.opt.Escape || (u.opt.Groups && (r == '(' || r == ')'))
+}
+
+// Проверяет, что между кластером cluster и символом r нет границы графемного кластера
+func continuesCluster(cluster string, r rune) bool {
+	candidate := cluster + string(r)
+	first, _, _, _ := uniseg.FirstGraphemeClusterInString(candidate, -1)
+
+	return len(first) == len(candidate)
+}
+
+/*
+Считывает содержимое группы до закрывающей скобки.
+Группа не разворачивается: хранятся её части с числами повторений,
+а размер одного повтора ограничен так же, как результат.
+*/
+func (u *unpackReader) readGroup(open rune, runeOffset, byteOffset int) (piece, error) {
+	group := piece{parts: []piece{}}
+
+	for {
+		part, err := u.readUnit(true)
+		if err == errGroupEnd {
+			break
+		}
+		if err == io.EOF {
+			// Незакрытая группа
+			if u.opt.Mode == ModeLenient {
+				break
+			}
+			return piece{}, &UnpackError{
+				Reason:     ReasonUnclosedGroup,
+				Rune:       open,
+				RuneOffset: runeOffset,
+				ByteOffset: byteOffset,
+			}
+		}
+		if err != nil {
+			return piece{}, err
+		}
+
+		part.count, err = u.readRepeat()
+		if err != nil {
+			return piece{}, err
+		}
+
+		if part.size == 0 {
+			continue
+		}
+		if !u.fits(group.size, part) {
+			return piece{}, ErrOutputTooLarge
+		}
+		group.size += part.size * part.count
+		group.parts = append(group.parts, part)
+	}
+
+	if group.size == 0 && u.opt.Mode == ModeStrict {
+		return piece{}, &UnpackError{
+			Reason:     ReasonEmptyGroup,
+			Rune:       open,
+			RuneOffset: runeOffset,
+			ByteOffset: byteOffset,
+		}
+	}
+
+	return group, nil
+}
+
+/*
+Считывает число повторений после единицы, если оно есть.
+Без числа единица записывается один раз, число 0 тоже означает один раз.
+*/
+func (u *unpackReader) readRepeat() (int64, error) {
+	r, err := u.readRune()
+	if err == io.EOF {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !isDigit(r) {
+		return 1, u.unreadRune()
+	}
+
+	count, err := u.readCount(r)
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		count = 1
+	}
+
+	return count, nil
+}
+
+// Считывает число повторений, первая цифра которого first
+func (u *unpackReader) readCount(first rune) (int64, error) {
+	count := int64(first - '0')
+
+	for {
+		r, err := u.readRune()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !isDigit(r) {
+			return count, u.unreadRune()
+		}
+
+		digit := int64(r - '0')
+		// Число повторений, не помещающееся в int64, заведомо слишком велико
+		if count > (math.MaxInt64-digit)/10 {
+			return 0, ErrOutputTooLarge
+		}
+		count = count*10 + digit
+	}
+}
+
+// Считывает серию цифр как есть, первая цифра которой first
+func (u *unpackReader) readDigits(first rune) (string, error) {
+	digits := []rune{first}
+
+	for {
+		r, err := u.readRune()
+		if err == io.EOF {
+			return string(digits), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if !isDigit(r) {
+			return string(digits), u.unreadRune()
+		}
+		digits = append(digits, r)
+	}
+}
+
+/*
+Проверяет, что все повторы единицы поместятся в результат после extra байт.
+Без ограничения размер результата должен помещаться в int64.
+*/
+func (u *unpackReader) fits(extra int64, unit piece) bool {
+	remaining := int64(math.MaxInt64)
+	if u.limit > 0 {
+		remaining = u.limit - u.written
+	}
+
+	return unit.count <= (remaining-extra)/unit.size
+}
+
+// Планирует запись единицы с проверкой ограничения размера
+func (u *unpackReader) emit(unit piece) error {
+	if unit.size == 0 {
+		return nil
+	}
+
+	if u.limit > 0 {
+		if !u.fits(0, unit) {
+			return ErrOutputTooLarge
+		}
+		u.written += unit.size * unit.count
+	}
+
+	u.stack = append(u.stack, cursor{piece: &unit, repeat: unit.count})
+
+	return nil
+}
+
+// Читает следующий символ входной строки, отслеживая позицию
+func (u *unpackReader) readRune() (rune, error) {
+	r, size, err := u.src.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+
+	u.runeOffset++
+	u.byteOffset += size
+	u.lastSize = size
+
+	return r, nil
+}
+
+// Возвращает последний прочитанный символ во входной поток
+func (u *unpackReader) unreadRune() error {
+	if err := u.src.UnreadRune(); err != nil {
+		return err
+	}
+
+	u.runeOffset--
+	u.byteOffset -= u.lastSize
+
+	return nil
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"unicode/utf8"
 )
 
 func TestUnpack(t *testing.T) {
@@ -69,8 +74,474 @@ func TestUnpack(t *testing.T) {
 				t.Errorf("expected %s, got %s", test.expected, result)
 			}
 
-			if err != test.err {
-				t.Errorf("expected %s, got %s", test.err.Error(), err.Error())
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestUnpackReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		err      error
+	}{
+		{
+			name:     "valid",
+			input:    `a4bc2d5e`,
+			expected: `aaaabccddddde`,
+			err:      nil,
+		},
+		{
+			name:     "valid",
+			input:    `пр3ив12ет`,
+			expected: `пррриввввввввввввет`,
+			err:      nil,
+		},
+		{
+			name:     "valid",
+			input:    `qwe\45`,
+			expected: `qwe44444`,
+			err:      nil,
+		},
+		{
+			name:     "valid",
+			input:    `qwe\\5`,
+			expected: `qwe\\\\\`,
+			err:      nil,
+		},
+		{
+			name:     "invalid",
+			input:    `45`,
+			expected: ``,
+			err:      ErrInvalidString,
+		},
+		{
+			name:     "invalid",
+			input:    `ab\`,
+			expected: `ab`,
+			err:      ErrInvalidString,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Читаем по одному байту, чтобы проверить разбиение многобайтовых символов
+			result, err := io.ReadAll(iotest.OneByteReader(UnpackReader(strings.NewReader(test.input))))
+
+			if string(result) != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, result)
+			}
+
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestUnpackError(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected UnpackError
+	}{
+		{
+			name:  "leading digit",
+			input: `45`,
+			expected: UnpackError{
+				Reason:     ReasonLeadingDigit,
+				Rune:       '4',
+				RuneOffset: 0,
+				ByteOffset: 0,
+			},
+		},
+		{
+			name:  "dangling escape",
+			input: `qwe\`,
+			expected: UnpackError{
+				Reason:     ReasonDanglingEscape,
+				Rune:       '\\',
+				RuneOffset: 3,
+				ByteOffset: 3,
+			},
+		},
+		{
+			name:  "dangling escape after multibyte",
+			input: `пр3и\`,
+			expected: UnpackError{
+				Reason:     ReasonDanglingEscape,
+				Rune:       '\\',
+				RuneOffset: 4,
+				ByteOffset: 7,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Unpack(test.input)
+
+			var unpackErr *UnpackError
+			if !errors.As(err, &unpackErr) {
+				t.Fatalf("expected *UnpackError, got %v", err)
+			}
+
+			if *unpackErr != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, *unpackErr)
+			}
+
+			if !errors.Is(err, ErrInvalidString) {
+				t.Errorf("expected %v to match %v", err, ErrInvalidString)
+			}
+		})
+	}
+}
+
+func TestUnpackReaderLazy(t *testing.T) {
+	// Распаковка целиком заняла бы гигабайты
+	r := UnpackReader(strings.NewReader(`a999999999`))
+
+	buf := make([]byte, 16)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(buf) != strings.Repeat("a", 16) {
+		t.Errorf("expected %s, got %s", strings.Repeat("a", 16), buf)
+	}
+}
+
+func TestUnpackReaderLazyGroups(t *testing.T) {
+	// Группа разворачивается по мере чтения, а не целиком в памяти
+	r := NewUnpackReader(strings.NewReader(`x(a99999999999999b)2`), Options{Groups: true})
+
+	buf := make([]byte, 16)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := "x" + strings.Repeat("a", 15); string(buf) != expected {
+		t.Errorf("expected %s, got %s", expected, buf)
+	}
+
+	// Размер вложенных групп, не помещающийся в int64, заведомо слишком велик
+	r = NewUnpackReader(strings.NewReader(`(((a999999999)999999999)999999999)`), Options{Groups: true})
+	if _, err := io.ReadFull(r, buf); !errors.Is(err, ErrOutputTooLarge) {
+		t.Errorf("expected %v, got %v", ErrOutputTooLarge, err)
+	}
+}
+
+func TestUnpackReaderLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		limit int64
+		err   error
+	}{
+		{
+			name:  "within limit",
+			input: `a5b5`,
+			limit: 10,
+			err:   nil,
+		},
+		{
+			name:  "bomb",
+			input: `a999999999`,
+			limit: 1 << 20,
+			err:   ErrOutputTooLarge,
+		},
+		{
+			name:  "multibyte",
+			input: `я5`,
+			limit: 9,
+			err:   ErrOutputTooLarge,
+		},
+		{
+			name:  "overflow",
+			input: `a99999999999999999999`,
+			limit: 0,
+			err:   ErrOutputTooLarge,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := io.Copy(io.Discard, UnpackReaderLimit(strings.NewReader(test.input), test.limit))
+
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestPack(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "runs",
+			input:    `aaaabccddddde`,
+			expected: `a4bc2d5e`,
+		},
+		{
+			name:     "no runs",
+			input:    `abcd`,
+			expected: `abcd`,
+		},
+		{
+			name:     "empty",
+			input:    ``,
+			expected: ``,
+		},
+		{
+			name:     "digits",
+			input:    `qwe45`,
+			expected: `qwe\4\5`,
+		},
+		{
+			name:     "repeated digit",
+			input:    `qwe44444`,
+			expected: `qwe\45`,
+		},
+		{
+			name:     "backslashes",
+			input:    `qwe\\\\\`,
+			expected: `qwe\\5`,
+		},
+		{
+			name:     "multibyte pair",
+			input:    `яя`,
+			expected: `я2`,
+		},
+		{
+			name:     "long run",
+			input:    strings.Repeat("b", 120),
+			expected: `b120`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Pack(test.input)
+
+			if result != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, result)
+			}
+		})
+	}
+}
+
+func FuzzPack(f *testing.F) {
+	for _, seed := range []string{``, `abcd`, `aaaabccddddde`, `qwe45`, `qwe\\\\\`, `яяя111`, "é́", `👍🏽👍🏽`} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		// Unpack работает с рунами, поэтому некорректный UTF-8 не сохраняется
+		if !utf8.ValidString(input) {
+			t.Skip()
+		}
+
+		packed := Pack(input)
+
+		result, err := Unpack(packed)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", packed, err)
+		}
+		if result != input {
+			t.Errorf("expected %q, got %q (packed %q)", input, result, packed)
+		}
+	})
+}
+
+func TestUnpackWithOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opt      Options
+		expected string
+		err      error
+	}{
+		{
+			name:     "default",
+			input:    `qwe\45(ab)`,
+			opt:      Options{},
+			expected: `qwe44444(ab)`,
+			err:      nil,
+		},
+		{
+			name:     "group",
+			input:    `x(ab)3y`,
+			opt:      Options{Groups: true},
+			expected: `xabababy`,
+			err:      nil,
+		},
+		{
+			name:     "nested group",
+			input:    `(a2(bc)2)2`,
+			opt:      Options{Groups: true},
+			expected: `aabcbcaabcbc`,
+			err:      nil,
+		},
+		{
+			name:     "escaped brackets",
+			input:    `\(a\)2`,
+			opt:      Options{Groups: true},
+			expected: `(a))`,
+			err:      nil,
+		},
+		{
+			name:     "custom escape",
+			input:    `qwe/4/52\3`,
+			opt:      Options{Escape: '/'},
+			expected: `qwe455\\\`,
+			err:      nil,
+		},
+		{
+			name:     "unclosed group",
+			input:    `(ab`,
+			opt:      Options{Groups: true},
+			expected: ``,
+			err:      ErrInvalidString,
+		},
+		{
+			name:     "unmatched group",
+			input:    `ab)`,
+			opt:      Options{Groups: true},
+			expected: ``,
+			err:      ErrInvalidString,
+		},
+		{
+			name:     "empty group",
+			input:    `a()3`,
+			opt:      Options{Groups: true},
+			expected: ``,
+			err:      ErrInvalidString,
+		},
+		{
+			name:     "lenient leading digits",
+			input:    `45a2`,
+			opt:      Options{Mode: ModeLenient},
+			expected: `45aa`,
+			err:      nil,
+		},
+		{
+			name:     "lenient dangling escape",
+			input:    `ab\`,
+			opt:      Options{Mode: ModeLenient},
+			expected: `ab\`,
+			err:      nil,
+		},
+		{
+			name:     "lenient groups",
+			input:    `a()3b)(cd`,
+			opt:      Options{Groups: true, Mode: ModeLenient},
+			expected: `ab)cd`,
+			err:      nil,
+		},
+		{
+			name:     "group limit",
+			input:    `(a999)999`,
+			opt:      Options{Groups: true, MaxOutput: 1000},
+			expected: ``,
+			err:      ErrOutputTooLarge,
+		},
+		{
+			name:     "digit escape",
+			input:    `a`,
+			opt:      Options{Escape: '1'},
+			expected: ``,
+			err:      ErrInvalidOptions,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := UnpackWithOptions(test.input, test.opt)
+
+			if result != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, result)
+			}
+
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestUnpackGraphemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opt      Options
+		expected string
+	}{
+		{
+			name:     "combining mark",
+			input:    "e\u03013",
+			opt:      Options{Graphemes: true},
+			expected: "e\u0301e\u0301e\u0301",
+		},
+		{
+			name:     "combining mark by runes",
+			input:    "e\u03013",
+			opt:      Options{},
+			expected: "e\u0301\u0301\u0301",
+		},
+		{
+			name:     "several combining marks",
+			input:    "ы\u0308\u03012x",
+			opt:      Options{Graphemes: true},
+			expected: "ы\u0308\u0301ы\u0308\u0301x",
+		},
+		{
+			name:     "skin tone modifier",
+			input:    "👍\U0001F3FD3",
+			opt:      Options{Graphemes: true},
+			expected: "👍\U0001F3FD👍\U0001F3FD👍\U0001F3FD",
+		},
+		{
+			name:     "zwj sequence",
+			input:    "a👩\u200d👩\u200d👧2",
+			opt:      Options{Graphemes: true},
+			expected: "a👩\u200d👩\u200d👧👩\u200d👩\u200d👧",
+		},
+		{
+			name:     "flags",
+			input:    "🇷🇺🇫🇷2",
+			opt:      Options{Graphemes: true},
+			expected: "🇷🇺🇫🇷🇫🇷",
+		},
+		{
+			name:     "escaped base",
+			input:    "\\\\\u03012",
+			opt:      Options{Graphemes: true},
+			expected: "\\\u0301\\\u0301",
+		},
+		{
+			name:     "group",
+			input:    "(e\u0301🇷🇺)2",
+			opt:      Options{Graphemes: true, Groups: true},
+			expected: "e\u0301🇷🇺e\u0301🇷🇺",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := UnpackWithOptions(test.input, test.opt)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
 			}
 		})
 	}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Строка, нарушающая порядок сортировки
+type DisorderError struct {
+	Line int    // номер строки, начиная с 1
+	Text string // содержимое строки
+}
+
+func (e *DisorderError) Error() string {
+	return fmt.Sprintf("%d: disorder: %s", e.Line, e.Text)
+}
+
+/*
+Проверяет, что строки r упорядочены согласно flg.
+Возвращает *DisorderError для первой строки, идущей не по порядку.
+С флагом -u повторяющиеся строки также считаются нарушением порядка.
+*/
+func checkSorted(r *lineReader, flg Flags, cmp *comparison) error {
+	compare, unique := cmp.lines(), cmp.unique()
+
+	var prev keyedLine
+	for line := 1; ; line++ {
+		str, err := r.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		current := cmp.keyed(str)
+		if line > 1 {
+			if compare(prev, current) > 0 || flg.UniqueValues && unique(prev.text, str) == 0 {
+				return &DisorderError{Line: line, Text: str}
+			}
+		}
+		prev = current
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Ошибка разбора названия локали
+var ErrInvalidLocale = errors.New("invalid locale")
+
+/*
+Разбирает название локали в форме POSIX (ru_RU.UTF-8) или BCP 47 (ru-RU).
+Кодировка и модификатор после точки или @ отбрасываются.
+*/
+func parseLocale(str string) (language.Tag, error) {
+	name, _, _ := strings.Cut(str, ".")
+	name, _, _ = strings.Cut(name, "@")
+	name = strings.ReplaceAll(name, "_", "-")
+
+	tag, err := language.Parse(name)
+	if err != nil {
+		return language.Und, fmt.Errorf("%w: %q", ErrInvalidLocale, str)
+	}
+
+	return tag, nil
+}
+
+/*
+Сравнение строк по Unicode Collation Algorithm с правилами локали.
+Collator хранит промежуточное состояние и не может использоваться
+из нескольких горутин одновременно, поэтому берётся из пула.
+*/
+type collation struct {
+	exact sync.Pool // с учётом регистра
+	fold  sync.Pool // без учёта регистра, для -f
+}
+
+// Создаёт сравнение по правилам локали, для пустой локали возвращает nil
+func newCollation(locale string) (*collation, error) {
+	if locale == "" {
+		return nil, nil
+	}
+
+	tag, err := parseLocale(locale)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &collation{}
+	c.exact.New = func() any { return collate.New(tag) }
+	c.fold.New = func() any { return collate.New(tag, collate.IgnoreCase) }
+
+	return c, nil
+}
+
+// Сравнивает строки, fold - без учёта регистра
+func (c *collation) compare(a, b string, fold bool) int {
+	pool := &c.exact
+	if fold {
+		pool = &c.fold
+	}
+
+	collator := pool.Get().(*collate.Collator)
+	defer pool.Put(collator)
+
+	return collator.CompareString(a, b)
+}
+
+// Оставляет в строке только буквы, цифры и пробелы, как при -d
+func dictionaryOrder(str string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return r
+		}
+		return -1
+	}, str)
+}
+
+/*
+Сравнивает строки как текст с учётом модификаторов f и d.
+С локалью используется её порядок сортировки, без локали -
+сравнение без учёта регистра, где при -f регистр не различается вовсе.
+*/
+func compareString(a, b string, opt KeyOptions, coll *collation) int {
+	if opt.Dictionary {
+		a, b = dictionaryOrder(a), dictionaryOrder(b)
+	}
+
+	switch {
+	case coll != nil:
+		return coll.compare(a, b, opt.Fold)
+	case opt.Fold:
+		return strings.Compare(strings.ToUpper(a), strings.ToUpper(b))
+	}
+
+	return compareText(a, b)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Примерный расход памяти на хранение строки помимо её байтов и на каждый её ключ
+const (
+	lineOverhead = 40
+	keyOverhead  = 16
+)
+
+/*
+Внешняя сортировка слиянием.
+Входные строки читаются частями, не превышающими flg.BufferSize байт.
+Каждая часть сортируется в памяти в flg.Parallel горутин и сбрасывается во временный файл,
+затем отсортированные файлы сливаются с помощью кучи.
+Если весь ввод поместился в память, временные файлы не создаются.
+*/
+func externalSort(r *lineReader, w io.Writer, flg Flags, cmp *comparison) (err error) {
+	compare := cmp.lines()
+	less := func(a, b keyedLine) bool { return compare(a, b) < 0 }
+
+	var runs []string
+	defer func() {
+		for _, run := range runs {
+			if removeErr := os.Remove(run); removeErr != nil && err == nil {
+				err = removeErr
+			}
+		}
+	}()
+
+	for {
+		chunk, eof, err := readChunk(r, flg.BufferSize, cmp)
+		if err != nil {
+			return err
+		}
+
+		chunk = parallelSort(chunk, flg.Parallel, flg.Stable, less)
+
+		// Весь ввод поместился в память
+		if eof && len(runs) == 0 {
+			out := newLineWriter(w, flg, cmp)
+			for _, keyed := range chunk {
+				if err := out.Write(keyed.text); err != nil {
+					return err
+				}
+			}
+			return out.Flush()
+		}
+
+		run, err := writeRun(chunk, flg.TempDir)
+		if run != "" {
+			runs = append(runs, run)
+		}
+		if err != nil {
+			return err
+		}
+
+		if eof {
+			break
+		}
+	}
+
+	return mergeRuns(runs, w, compare, flg, cmp)
+}
+
+// Читает строки с ключами, пока их суммарный размер не превысит limit байт
+func readChunk(r *lineReader, limit int64, cmp *comparison) ([]keyedLine, bool, error) {
+	var (
+		chunk []keyedLine
+		size  int64
+	)
+
+	for size < limit || len(chunk) == 0 {
+		str, err := r.ReadLine()
+		if err == io.EOF {
+			return chunk, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		keyed := cmp.keyed(str)
+		chunk = append(chunk, keyed)
+		size += int64(len(str)) + lineOverhead + int64(len(keyed.keys))*keyOverhead
+	}
+
+	return chunk, false, nil
+}
+
+// Читает строку без символа перевода строки
+func readLine(r *bufio.Reader) (string, error) {
+	str, err := r.ReadString('\n')
+	if err == io.EOF && str != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	str = strings.TrimSuffix(str, "\n")
+	str = strings.TrimSuffix(str, "\r")
+
+	return str, nil
+}
+
+// Записывает отсортированную часть во временный файл и возвращает его имя
+func writeRun(chunk []keyedLine, dir string) (string, error) {
+	file, err := os.CreateTemp(dir, "sort-run-*")
+	if err != nil {
+		return "", err
+	}
+
+	w := bufio.NewWriter(file)
+	for _, keyed := range chunk {
+		if _, err := fmt.Fprintln(w, keyed.text); err != nil {
+			file.Close()
+			return file.Name(), err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return file.Name(), err
+	}
+
+	return file.Name(), file.Close()
+}
+
+// Текущая строка одного из сливаемых потоков
+type mergeItem struct {
+	keyed  keyedLine
+	run    int
+	line   int // номер текущей строки в потоке
+	warned bool
+	reader *bufio.Reader
+}
+
+// Куча строк сливаемых потоков, при равенстве строк раньше идёт более ранний поток
+type mergeHeap struct {
+	items   []*mergeItem
+	compare func(a, b keyedLine) int
+}
+
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+func (h *mergeHeap) Less(i, j int) bool {
+	if c := h.compare(h.items[i].keyed, h.items[j].keyed); c != 0 {
+		return c < 0
+	}
+
+	return h.items[i].run < h.items[j].run
+}
+
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+func (h *mergeHeap) Push(x any) { h.items = append(h.items, x.(*mergeItem)) }
+
+func (h *mergeHeap) Pop() any {
+	item := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return item
+}
+
+// Сливает отсортированные временные файлы в w
+func mergeRuns(runs []string, w io.Writer, compare func(a, b keyedLine) int, flg Flags, cmp *comparison) error {
+	readers := make([]*bufio.Reader, len(runs))
+	for i, run := range runs {
+		file, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		readers[i] = bufio.NewReader(file)
+	}
+
+	return mergeReaders(readers, w, compare, flg, cmp, nil)
+}
+
+/*
+Сливает отсортированные потоки в w, не загружая их в память.
+Если warn не nil, порядок строк каждого потока проверяется по ходу слияния
+и о первом нарушении в потоке сообщается warn с номером потока.
+*/
+func mergeReaders(
+	readers []*bufio.Reader,
+	w io.Writer,
+	compare func(a, b keyedLine) int,
+	flg Flags,
+	cmp *comparison,
+	warn func(input int, disorder *DisorderError),
+) error {
+	h := &mergeHeap{compare: compare}
+
+	for i, reader := range readers {
+		item := &mergeItem{run: i, line: 1, reader: reader}
+
+		str, err := readLine(reader)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		item.keyed = cmp.keyed(str)
+		h.items = append(h.items, item)
+	}
+	heap.Init(h)
+
+	out := newLineWriter(w, flg, cmp)
+	for h.Len() > 0 {
+		item := h.items[0]
+		if err := out.Write(item.keyed.text); err != nil {
+			return err
+		}
+
+		str, err := readLine(item.reader)
+		if err == io.EOF {
+			heap.Pop(h)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		item.line++
+
+		next := cmp.keyed(str)
+		if warn != nil && !item.warned && compare(item.keyed, next) > 0 {
+			warn(item.run, &DisorderError{Line: item.line, Text: str})
+			item.warned = true
+		}
+
+		item.keyed = next
+		heap.Fix(h, 0)
+	}
+
+	return out.Flush()
+}
+
+/*
+Записывает отсортированные строки в выходной поток.
+С флагом -u из повторяющихся строк записывается только первая. Повторы удаляются
+здесь, при окончательном выводе или слиянии, а не в отдельных частях,
+поэтому результат не зависит от того, сбрасывались ли части на диск.
+*/
+type lineWriter struct {
+	w       *bufio.Writer
+	compare func(a, b string) int
+	unique  bool
+
+	last    string // последняя записанная строка
+	written bool
+}
+
+func newLineWriter(w io.Writer, flg Flags, cmp *comparison) *lineWriter {
+	return &lineWriter{
+		w:       bufio.NewWriter(w),
+		compare: cmp.unique(),
+		unique:  flg.UniqueValues,
+	}
+}
+
+func (lw *lineWriter) Write(str string) error {
+	if lw.unique && lw.written && lw.compare(lw.last, str) == 0 {
+		return nil
+	}
+
+	lw.last = str
+	lw.written = true
+
+	_, err := fmt.Fprintln(lw.w, str)
+	return err
+}
+
+// Записывает строки, оставшиеся в буфере
+func (lw *lineWriter) Flush() error {
+	return lw.w.Flush()
+}
+
+// Ошибка разбора размера буфера
+var errInvalidSize = errors.New("expected number with optional suffix b, K, M, G or T")
+
+// Разбирает размер вида 512K, 64M, 1G, число без суффикса задаёт байты
+func parseSize(str string) (int64, error) {
+	multiplier := int64(1)
+
+	if str != "" {
+		switch str[len(str)-1] {
+		case 'b', 'B':
+			str = str[:len(str)-1]
+		case 'k', 'K':
+			multiplier, str = 1<<10, str[:len(str)-1]
+		case 'm', 'M':
+			multiplier, str = 1<<20, str[:len(str)-1]
+		case 'g', 'G':
+			multiplier, str = 1<<30, str[:len(str)-1]
+		case 't', 'T':
+			multiplier, str = 1<<40, str[:len(str)-1]
+		}
+	}
+
+	size, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || size <= 0 || size > (1<<62)/multiplier {
+		return 0, errInvalidSize
+	}
+
+	return size * multiplier, nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ошибка разбора описания ключа сортировки
+var ErrInvalidKey = errors.New("invalid key specification")
+
+// Модификаторы сравнения ключа
+type KeyOptions struct {
+	Numeric     bool // n - сравнивать как число
+	General     bool // g - сравнивать как число с плавающей точкой
+	Human       bool // h - сравнивать как число с суффиксом K, M, G...
+	Version     bool // V - сравнивать как номера версий
+	Month       bool // M - сравнивать как название месяца
+	Fold        bool // f - не различать регистр
+	Dictionary  bool // d - учитывать только буквы, цифры и пробелы
+	Reverse     bool // r - обратный порядок
+	StartBlanks bool // b в POS1 - пропускать пробелы в начале поля
+	EndBlanks   bool // b в POS2 - пропускать пробелы в начале последнего поля
+}
+
+// Задан ли хотя бы один модификатор
+func (opt KeyOptions) isSet() bool {
+	return opt != KeyOptions{}
+}
+
+// Задан ли модификатор, сравнивающий значения не как текст
+func (opt KeyOptions) converts() bool {
+	return opt.Numeric || opt.General || opt.Human || opt.Version || opt.Month
+}
+
+/*
+Ключ сортировки -k POS1[,POS2], где POS = F[.C][OPTS].
+Поля и символы нумеруются с 1. EndField = 0 означает конец строки,
+EndChar = 0 - конец поля EndField.
+*/
+type KeySpec struct {
+	StartField int
+	StartChar  int
+	EndField   int
+	EndChar    int
+	Options    KeyOptions
+}
+
+/*
+Разбирает описание ключа, например "2,2nr", "1.3b,1.5" или "3".
+Модификатор b относится к позиции, после которой указан,
+остальные модификаторы - ко всему ключу.
+*/
+func ParseKey(str string) (KeySpec, error) {
+	var key KeySpec
+
+	start, end, hasEnd := strings.Cut(str, ",")
+
+	field, char, err := parsePosition(start, &key.Options, false)
+	if err != nil {
+		return KeySpec{}, fmt.Errorf("%w: %q: %v", ErrInvalidKey, str, err)
+	}
+	if field == 0 || char == 0 {
+		return KeySpec{}, fmt.Errorf("%w: %q: field and character numbers start at 1", ErrInvalidKey, str)
+	}
+	if char < 0 {
+		char = 1
+	}
+	key.StartField, key.StartChar = field, char
+
+	if hasEnd {
+		field, char, err := parsePosition(end, &key.Options, true)
+		if err != nil {
+			return KeySpec{}, fmt.Errorf("%w: %q: %v", ErrInvalidKey, str, err)
+		}
+		if field == 0 {
+			return KeySpec{}, fmt.Errorf("%w: %q: field numbers start at 1", ErrInvalidKey, str)
+		}
+		if char < 0 {
+			char = 0
+		}
+		key.EndField, key.EndChar = field, char
+	}
+
+	return key, nil
+}
+
+/*
+Разбирает позицию F[.C][OPTS] и добавляет модификаторы в opt.
+Если номер символа не указан, возвращается -1.
+*/
+func parsePosition(str string, opt *KeyOptions, end bool) (int, int, error) {
+	digits := strings.IndexFunc(str, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	if digits < 0 {
+		digits = len(str)
+	}
+	position, modifiers := str[:digits], str[digits:]
+
+	fieldStr, charStr, hasChar := strings.Cut(position, ".")
+	field, err := strconv.Atoi(fieldStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad field number %q", fieldStr)
+	}
+
+	char := -1
+	if hasChar {
+		if char, err = strconv.Atoi(charStr); err != nil {
+			return 0, 0, fmt.Errorf("bad character number %q", charStr)
+		}
+	}
+
+	for _, m := range modifiers {
+		if err := opt.set(m, end); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return field, char, nil
+}
+
+// Включает модификатор m, end - модификатор указан после POS2
+func (opt *KeyOptions) set(m rune, end bool) error {
+	switch m {
+	case 'n':
+		opt.Numeric = true
+	case 'g':
+		opt.General = true
+	case 'h':
+		opt.Human = true
+	case 'V':
+		opt.Version = true
+	case 'M':
+		opt.Month = true
+	case 'f':
+		opt.Fold = true
+	case 'd':
+		opt.Dictionary = true
+	case 'r':
+		opt.Reverse = true
+	case 'b':
+		if end {
+			opt.EndBlanks = true
+		} else {
+			opt.StartBlanks = true
+		}
+	default:
+		return fmt.Errorf("unknown modifier %q", m)
+	}
+
+	return nil
+}
+
+// Список ключей для повторяемого флага -k
+type keyList []KeySpec
+
+func (k *keyList) String() string {
+	return fmt.Sprint(*k)
+}
+
+func (k *keyList) Set(str string) error {
+	key, err := ParseKey(str)
+	if err != nil {
+		return err
+	}
+
+	*k = append(*k, key)
+	return nil
+}
+
+// Границы поля в строке в байтах
+type span struct {
+	start, end int
+}
+
+/*
+Разбивает строку на поля.
+Без разделителя поле - это пробелы перед ним и следующие за ними непробельные символы,
+с разделителем sep поля разделяются каждым его вхождением.
+*/
+func fieldSpans(str, sep string) []span {
+	var fields []span
+
+	if sep != "" {
+		start := 0
+		for {
+			i := strings.Index(str[start:], sep)
+			if i < 0 {
+				return append(fields, span{start, len(str)})
+			}
+			fields = append(fields, span{start, start + i})
+			start += i + len(sep)
+		}
+	}
+
+	for start := 0; start < len(str); {
+		end := skipBlanks(str, start)
+		for end < len(str) && !isBlank(str[end]) {
+			end++
+		}
+		fields = append(fields, span{start, end})
+		start = end
+	}
+
+	return fields
+}
+
+// Выделяет из строки ключ key, fields - поля строки из fieldSpans
+func extractKey(str string, fields []span, key KeySpec) string {
+	if key.StartField > len(fields) {
+		return ""
+	}
+
+	field := fields[key.StartField-1]
+	start := field.start
+	if key.Options.StartBlanks {
+		start = skipBlanks(str, start)
+	}
+	start = advanceRunes(str, start, field.end, key.StartChar-1)
+
+	end := len(str)
+	if key.EndField > 0 && key.EndField <= len(fields) {
+		field := fields[key.EndField-1]
+		end = field.end
+		if key.EndChar > 0 {
+			pos := field.start
+			if key.Options.EndBlanks {
+				pos = skipBlanks(str, pos)
+			}
+			end = advanceRunes(str, pos, field.end, key.EndChar)
+		}
+	}
+
+	if end <= start {
+		return ""
+	}
+
+	return str[start:end]
+}
+
+// Сдвигает позицию pos на n символов, не выходя за limit
+func advanceRunes(str string, pos, limit, n int) int {
+	for ; n > 0 && pos < limit; n-- {
+		_, size := utf8.DecodeRuneInString(str[pos:])
+		pos += size
+	}
+
+	if pos > limit {
+		return limit
+	}
+	return pos
+}
+
+// Пропускает пробелы и табуляции начиная с позиции pos
+func skipBlanks(str string, pos int) int {
+	for pos < len(str) && isBlank(str[pos]) {
+		pos++
+	}
+
+	return pos
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Числовой префикс строки для -g: десятичное число с экспонентой, inf или nan
+var generalNumber = regexp.MustCompile(`^[+-]?(?i:(\d+\.?\d*|\.\d+)(e[+-]?\d+)?|inf(inity)?|nan)`)
+
+/*
+Разбирает начало строки как число с плавающей точкой.
+Пробелы в начале пропускаются, символы после числа игнорируются.
+Если строка не начинается с числа, возвращается false.
+*/
+func parseGeneral(str string) (float64, bool) {
+	prefix := generalNumber.FindString(strings.TrimLeft(str, " \t"))
+	if prefix == "" {
+		return 0, false
+	}
+
+	x, err := strconv.ParseFloat(prefix, 64)
+	if err != nil {
+		// Выход за пределы float64 даёт ±Inf вместе с ошибкой
+		if !math.IsInf(x, 0) {
+			return 0, false
+		}
+	}
+
+	return x, true
+}
+
+// Порядок значений для -g: не числа, затем NaN, затем числа по возрастанию
+func generalRank(x float64, ok bool) int {
+	switch {
+	case !ok:
+		return 0
+	case math.IsNaN(x):
+		return 1
+	}
+
+	return 2
+}
+
+// Сравнивает строки как числа с плавающей точкой
+func compareGeneral(a, b string) int {
+	x, okX := parseGeneral(a)
+	y, okY := parseGeneral(b)
+
+	if c := generalRank(x, okX) - generalRank(y, okY); c != 0 {
+		return c
+	}
+
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+
+	return 0
+}
+
+/*
+Вес символа для сравнения версий: '~' идёт раньше конца строки,
+буквы - раньше остальных символов. Позиция за концом строки имеет вес 0.
+*/
+func versionOrder(str string, i int) int {
+	if i >= len(str) {
+		return 0
+	}
+
+	c := str[i]
+	switch {
+	case isDigitByte(c):
+		return 0
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	}
+
+	return int(c) + 256
+}
+
+func isDigitByte(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+/*
+Сравнивает строки в порядке версий: нецифровые части сравниваются посимвольно,
+последовательности цифр - как числа. file2 < file10, 1.2.9 < 1.2.10, 1.0~rc1 < 1.0.
+*/
+func compareVersions(a, b string) int {
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		for i < len(a) && !isDigitByte(a[i]) || j < len(b) && !isDigitByte(b[j]) {
+			if c := versionOrder(a, i) - versionOrder(b, j); c != 0 {
+				return c
+			}
+			i++
+			j++
+		}
+
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+
+		// При равной длине чисел решает первая различающаяся цифра
+		firstDiff := 0
+		for i < len(a) && isDigitByte(a[i]) && j < len(b) && isDigitByte(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i++
+			j++
+		}
+
+		if i < len(a) && isDigitByte(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigitByte(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return firstDiff
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Форматы структурированных записей для -format, пустой формат - обычные строки
+const (
+	FormatText  = ""
+	FormatCSV   = "csv"
+	FormatTSV   = "tsv"
+	FormatJSONL = "jsonl"
+)
+
+var (
+	// Ошибка неизвестного формата записей
+	ErrUnknownFormat = errors.New("unknown format")
+	// Ошибка ключа по полю, которого нет в заголовке CSV/TSV
+	ErrUnknownField = errors.New("unknown field")
+	// Ошибка несовпадения заголовков нескольких входных CSV/TSV
+	ErrHeaderMismatch = errors.New("header mismatch")
+)
+
+// Проверяет, что формат записей поддерживается
+func validateFormat(format string) error {
+	switch format {
+	case FormatText, FormatCSV, FormatTSV, FormatJSONL:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+/*
+Ключ сортировки записей по имени поля: NAME[:OPTS].
+Для CSV/TSV имя - название столбца из заголовка,
+для JSON Lines - путь к полю объекта, например .user.id или .items.0.
+Модификаторы те же, что и у -k.
+*/
+type FieldKey struct {
+	Name    string
+	Options KeyOptions
+}
+
+// Разбирает описание ключа по полю, например ".user.id:n" или "name:fr"
+func ParseFieldKey(str string) (FieldKey, error) {
+	key := FieldKey{Name: str}
+
+	if i := strings.LastIndex(str, ":"); i >= 0 {
+		key.Name = str[:i]
+		for _, m := range str[i+1:] {
+			if err := key.Options.set(m, false); err != nil {
+				return FieldKey{}, fmt.Errorf("%w: %q: %v", ErrInvalidKey, str, err)
+			}
+		}
+	}
+
+	if key.Name == "" {
+		return FieldKey{}, fmt.Errorf("%w: %q: empty field name", ErrInvalidKey, str)
+	}
+
+	return key, nil
+}
+
+// Список ключей для повторяемого флага -field
+type fieldList []FieldKey
+
+func (f *fieldList) String() string {
+	return fmt.Sprint(*f)
+}
+
+func (f *fieldList) Set(str string) error {
+	key, err := ParseFieldKey(str)
+	if err != nil {
+		return err
+	}
+
+	*f = append(*f, key)
+	return nil
+}
+
+// Тип значения поля, значения разных типов упорядочиваются по нему
+type valueKind int
+
+const (
+	kindMissing valueKind = iota // поля нет в записи
+	kindNull
+	kindBool
+	kindNumber
+	kindString
+	kindComposite // объект или массив JSON
+)
+
+// Значение ключевого поля записи
+type fieldValue struct {
+	kind   valueKind
+	text   string // текстовое представление для сравнения с модификаторами
+	number float64
+	flag   bool
+}
+
+// Запись CSV/TSV или JSON Lines
+type record struct {
+	fields []string     // поля CSV/TSV для вывода
+	text   string       // исходная строка JSON или поля через разделитель, для сравнения целиком
+	keys   []string     // ключи -k по полям записи, когда -field не задан
+	values []fieldValue // значения ключевых полей в порядке flg.Fields
+}
+
+/*
+Сравнивает значения поля.
+Строки, а также любые значения при модификаторах n, g, h, V, M,
+сравниваются как текст с учётом модификаторов. Исключение - два числа JSON
+при модификаторах n, g, h: они уже разобраны и сравниваются по значению.
+Значения разных типов упорядочиваются так:
+отсутствующие, null, логические, числа, строки, объекты и массивы.
+*/
+func (cmp *comparison) value(a, b fieldValue, opt KeyOptions) int {
+	numbers := a.kind == kindNumber && b.kind == kindNumber &&
+		(opt.Numeric || opt.General || opt.Human)
+
+	if !numbers && a.kind != kindMissing && b.kind != kindMissing &&
+		(opt.converts() || a.kind == kindString && b.kind == kindString) {
+		return cmp.key(a.text, b.text, opt)
+	}
+
+	var c int
+	switch {
+	case a.kind != b.kind:
+		c = int(a.kind) - int(b.kind)
+	case a.kind == kindNumber && a.number < b.number:
+		c = -1
+	case a.kind == kindNumber && a.number > b.number:
+		c = 1
+	case a.kind == kindBool && a.flag != b.flag:
+		c = 1
+		if !a.flag {
+			c = -1
+		}
+	case a.kind == kindComposite:
+		c = strings.Compare(a.text, b.text)
+	}
+
+	if opt.Reverse {
+		return -c
+	}
+	return c
+}
+
+/*
+Формирует функцию сравнения записей по ключевым полям.
+Без -field записи сравниваются по глобальным флагам и ключам -k,
+номера полей в которых - номера столбцов CSV/TSV.
+При равенстве ключей записи, как и строки, сравниваются целиком:
+с флагом -u сначала так, чтобы повторы шли подряд, затем, если не задан -s,
+в порядке последней надежды.
+*/
+func (cmp *comparison) records() func(a, b *record) int {
+	var keyCompare func(a, b *record) int
+
+	if len(cmp.flg.Fields) == 0 {
+		lineCompare := cmp.compareKeys()
+		keyCompare = func(a, b *record) int {
+			return lineCompare(keyedLine{a.text, a.keys}, keyedLine{b.text, b.keys})
+		}
+	} else {
+		global := globalOptions(cmp.flg)
+
+		options := make([]KeyOptions, len(cmp.flg.Fields))
+		for i, key := range cmp.flg.Fields {
+			options[i] = key.Options
+			if !key.Options.isSet() {
+				options[i] = global
+			}
+		}
+
+		keyCompare = func(a, b *record) int {
+			for i, opt := range options {
+				if c := cmp.value(a.values[i], b.values[i], opt); c != 0 {
+					return c
+				}
+			}
+
+			return 0
+		}
+	}
+
+	var whole []func(a, b string) int
+	if cmp.flg.UniqueValues {
+		whole = append(whole, cmp.unique())
+	}
+	if !cmp.flg.Stable {
+		whole = append(whole, cmp.lastResort())
+	}
+
+	return func(a, b *record) int {
+		if c := keyCompare(a, b); c != 0 {
+			return c
+		}
+
+		for _, compare := range whole {
+			if c := compare(a.text, b.text); c != 0 {
+				return c
+			}
+		}
+
+		return 0
+	}
+}
+
+/*
+Сортирует записи всех входных потоков в памяти и выводит их в том же формате.
+Заголовок CSV/TSV берётся из первого потока и выводится первым,
+заголовки остальных потоков должны с ним совпадать.
+*/
+func sortRecords(inputs []io.Reader, w io.Writer, flg Flags, cmp *comparison) error {
+	var (
+		header  []string
+		records []*record
+		err     error
+	)
+
+	switch flg.Format {
+	case FormatCSV, FormatTSV:
+		header, records, err = readTable(inputs, flg.Format, flg.Fields)
+	case FormatJSONL:
+		records, err = readJSONLines(inputs, flg.Fields)
+	default:
+		err = validateFormat(flg.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Ключи -k CSV/TSV выделяются из разобранных полей, а не из строки
+	if len(flg.Fields) == 0 {
+		for _, rec := range records {
+			rec.keys = cmp.keyedFields(rec.text, columnSpans(rec.fields)).keys
+		}
+	}
+
+	compare := cmp.records()
+	records = parallelSort(records, flg.Parallel, flg.Stable, func(a, b *record) bool {
+		return compare(a, b) < 0
+	})
+
+	// С флагом -u, как и для строк, из повторяющихся записей остаётся первая
+	if flg.UniqueValues {
+		unique := cmp.unique()
+
+		kept := records[:0]
+		for i, rec := range records {
+			if i == 0 || unique(kept[len(kept)-1].text, rec.text) != 0 {
+				kept = append(kept, rec)
+			}
+		}
+		records = kept
+	}
+
+	// Строки JSON и TSV выводятся так же, как были прочитаны
+	if flg.Format != FormatCSV {
+		out := bufio.NewWriter(w)
+		if header != nil {
+			if _, err := fmt.Fprintln(out, strings.Join(header, "\t")); err != nil {
+				return err
+			}
+		}
+		for _, rec := range records {
+			if _, err := fmt.Fprintln(out, rec.text); err != nil {
+				return err
+			}
+		}
+		return out.Flush()
+	}
+
+	out := csv.NewWriter(w)
+	if header != nil {
+		if err := out.Write(header); err != nil {
+			return err
+		}
+	}
+	for _, rec := range records {
+		if err := out.Write(rec.fields); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+
+	return out.Error()
+}
+
+// Источник строк таблицы, разбитых на поля
+type tableReader interface {
+	Read() ([]string, error)
+}
+
+/*
+Читает строки TSV. В отличие от CSV, кавычки в TSV не имеют особого смысла:
+поля разделяются только табуляцией и сохраняются как есть.
+*/
+type tsvReader struct {
+	reader *bufio.Reader
+}
+
+func (r tsvReader) Read() ([]string, error) {
+	str, err := readLine(r.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(str, "\t"), nil
+}
+
+// Создаёт читателя таблицы в формате format
+func newTableReader(input io.Reader, format string) tableReader {
+	if format == FormatTSV {
+		return tsvReader{bufio.NewReader(input)}
+	}
+
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+	return reader
+}
+
+// Читает записи CSV/TSV с заголовком
+func readTable(inputs []io.Reader, format string, keys []FieldKey) ([]string, []*record, error) {
+	var (
+		header  []string
+		columns []int
+		records []*record
+	)
+
+	comma := ","
+	if format == FormatTSV {
+		comma = "\t"
+	}
+
+	for _, input := range inputs {
+		reader := newTableReader(input, format)
+
+		first, err := reader.Read()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if header == nil {
+			header = first
+			if columns, err = findColumns(header, keys); err != nil {
+				return nil, nil, err
+			}
+		} else if !slices.Equal(header, first) {
+			return nil, nil, fmt.Errorf("%w: %q and %q", ErrHeaderMismatch, header, first)
+		}
+
+		for {
+			fields, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, err
+			}
+
+			rec := &record{
+				fields: fields,
+				text:   strings.Join(fields, comma),
+				values: make([]fieldValue, len(columns)),
+			}
+			for i, column := range columns {
+				if column < len(fields) {
+					rec.values[i] = fieldValue{kind: kindString, text: fields[column]}
+				}
+			}
+
+			records = append(records, rec)
+		}
+	}
+
+	return header, records, nil
+}
+
+/*
+Границы полей записи CSV/TSV в строке text, где поля записаны
+через односимвольный разделитель. Ключ -k N относится к N-му полю записи.
+*/
+func columnSpans(fields []string) []span {
+	spans := make([]span, len(fields))
+
+	start := 0
+	for i, field := range fields {
+		spans[i] = span{start, start + len(field)}
+		start += len(field) + 1
+	}
+
+	return spans
+}
+
+// Возвращает номера столбцов ключевых полей по заголовку
+func findColumns(header []string, keys []FieldKey) ([]int, error) {
+	columns := make([]int, len(keys))
+
+	for i, key := range keys {
+		columns[i] = -1
+		for j, name := range header {
+			if name == key.Name {
+				columns[i] = j
+				break
+			}
+		}
+
+		if columns[i] < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownField, key.Name)
+		}
+	}
+
+	return columns, nil
+}
+
+// Читает записи JSON Lines, пустые строки пропускаются
+func readJSONLines(inputs []io.Reader, keys []FieldKey) ([]*record, error) {
+	paths := make([][]string, len(keys))
+	for i, key := range keys {
+		if path := strings.TrimPrefix(key.Name, "."); path != "" {
+			paths[i] = strings.Split(path, ".")
+		}
+	}
+
+	var records []*record
+	for _, input := range inputs {
+		reader := bufio.NewReader(input)
+
+		for line := 1; ; line++ {
+			str, err := readLine(reader)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if strings.TrimSpace(str) == "" {
+				continue
+			}
+
+			decoder := json.NewDecoder(strings.NewReader(str))
+			decoder.UseNumber()
+
+			var object any
+			if err := decoder.Decode(&object); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+
+			rec := &record{text: str, values: make([]fieldValue, len(paths))}
+			for i, path := range paths {
+				rec.values[i] = jsonValue(lookupPath(object, path))
+			}
+
+			records = append(records, rec)
+		}
+	}
+
+	return records, nil
+}
+
+// Ищет значение по пути из имён полей объектов и индексов массивов
+func lookupPath(value any, path []string) (any, bool) {
+	for _, name := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			field, ok := v[name]
+			if !ok {
+				return nil, false
+			}
+			value = field
+		case []any:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// Преобразует значение JSON в значение поля для сравнения
+func jsonValue(value any, ok bool) fieldValue {
+	if !ok {
+		return fieldValue{kind: kindMissing}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return fieldValue{kind: kindNull, text: "null"}
+	case bool:
+		return fieldValue{kind: kindBool, text: strconv.FormatBool(v), flag: v}
+	case json.Number:
+		number, err := v.Float64()
+		if err != nil {
+			// Число вне диапазона float64 сравнивается как текст
+			return fieldValue{kind: kindString, text: v.String()}
+		}
+		return fieldValue{kind: kindNumber, text: v.String(), number: number}
+	case string:
+		return fieldValue{kind: kindString, text: v}
+	}
+
+	// Объекты и массивы сравниваются по их записи в JSON
+	data, _ := json.Marshal(value)
+	return fieldValue{kind: kindComposite, text: string(data)}
+}
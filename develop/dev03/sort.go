@@ -0,0 +1,247 @@
+package main
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// Размер части, которую выгоднее сортировать вставками
+const insertionThreshold = 12
+
+/*
+Сортирует слайс строк или записей, разбивая его на parallel частей.
+Части сортируются одновременно, затем попарно сливаются.
+При parallel <= 1 сортировка выполняется в текущей горутине.
+При stable равные строки сохраняют исходный порядок.
+*/
+func parallelSort[T any](strs []T, parallel int, stable bool, less func(a, b T) bool) []T {
+	sortPart := introSort[T]
+	if stable {
+		sortPart = mergeSort[T]
+	}
+
+	if parallel <= 1 || len(strs) < 2*parallel {
+		sortPart(strs, less)
+		return strs
+	}
+
+	// Разбиваем слайс на части примерно одинакового размера
+	parts := make([][]T, parallel)
+	for i := range parts {
+		parts[i] = strs[i*len(strs)/parallel : (i+1)*len(strs)/parallel]
+	}
+
+	var wg sync.WaitGroup
+	for _, part := range parts {
+		wg.Add(1)
+		go func(part []T) {
+			defer wg.Done()
+			sortPart(part, less)
+		}(part)
+	}
+	wg.Wait()
+
+	// Попарно сливаем отсортированные части, пока не останется одна
+	buf := make([]T, len(strs))
+	for len(parts) > 1 {
+		merged := make([][]T, 0, (len(parts)+1)/2)
+		offset := 0
+
+		for i := 0; i < len(parts); i += 2 {
+			if i+1 == len(parts) {
+				dst := buf[offset : offset+len(parts[i])]
+				copy(dst, parts[i])
+				merged = append(merged, dst)
+				break
+			}
+
+			size := len(parts[i]) + len(parts[i+1])
+			dst := buf[offset : offset+size]
+			offset += size
+
+			wg.Add(1)
+			go func(a, b, dst []T) {
+				defer wg.Done()
+				mergeSorted(a, b, dst, less)
+			}(parts[i], parts[i+1], dst)
+
+			merged = append(merged, dst)
+		}
+		wg.Wait()
+
+		parts = merged
+		strs, buf = buf, strs
+	}
+
+	return parts[0]
+}
+
+// Сливает отсортированные слайсы a и b в dst
+func mergeSorted[T any](a, b, dst []T, less func(a, b T) bool) {
+	i, j, k := 0, 0, 0
+	for i < len(a) && j < len(b) {
+		// При равенстве раньше идёт элемент из a, чтобы сохранить порядок частей
+		if less(b[j], a[i]) {
+			dst[k] = b[j]
+			j++
+		} else {
+			dst[k] = a[i]
+			i++
+		}
+		k++
+	}
+
+	k += copy(dst[k:], a[i:])
+	copy(dst[k:], b[j:])
+}
+
+/*
+Устойчивая сортировка слиянием: короткие отрезки сортируются вставками,
+затем сливаются снизу вверх через вспомогательный буфер.
+*/
+func mergeSort[T any](strs []T, less func(a, b T) bool) {
+	if len(strs) < 2 {
+		return
+	}
+
+	for start := 0; start < len(strs); start += insertionThreshold {
+		end := start + insertionThreshold - 1
+		if end >= len(strs) {
+			end = len(strs) - 1
+		}
+		insertionSort(strs, start, end, less)
+	}
+
+	src, dst := strs, make([]T, len(strs))
+	for width := insertionThreshold; width < len(strs); width *= 2 {
+		for start := 0; start < len(strs); start += 2 * width {
+			mid := min(start+width, len(strs))
+			end := min(start+2*width, len(strs))
+			mergeSorted(src[start:mid], src[mid:end], dst[start:end], less)
+		}
+		src, dst = dst, src
+	}
+
+	// Результат последнего слияния мог остаться во вспомогательном буфере
+	if &src[0] != &strs[0] {
+		copy(strs, src)
+	}
+}
+
+/*
+Интроспективная сортировка: быстрая сортировка с медианой из трёх,
+при превышении глубины рекурсии 2*log2(n) - пирамидальная сортировка,
+для коротких частей - сортировка вставками. Худший случай O(n log n).
+*/
+func introSort[T any](strs []T, less func(a, b T) bool) {
+	if len(strs) < 2 {
+		return
+	}
+
+	introSortRange(strs, 0, len(strs)-1, 2*bits.Len(uint(len(strs))), less)
+}
+
+func introSortRange[T any](strs []T, start, end, depth int, less func(a, b T) bool) {
+	for end-start+1 > insertionThreshold {
+		if depth == 0 {
+			heapSort(strs[start:end+1], less)
+			return
+		}
+		depth--
+
+		p := partition(strs, start, end, less)
+
+		// Рекурсия по меньшей части, цикл по большей: глубина стека O(log n)
+		if p-start < end-p {
+			introSortRange(strs, start, p-1, depth, less)
+			start = p + 1
+		} else {
+			introSortRange(strs, p+1, end, depth, less)
+			end = p - 1
+		}
+	}
+
+	insertionSort(strs, start, end, less)
+}
+
+/*
+Разбиение Хоара с опорным элементом - медианой первого, среднего и последнего.
+Возвращает итоговую позицию опорного элемента.
+*/
+func partition[T any](strs []T, start, end int, less func(a, b T) bool) int {
+	mid := start + (end-start)/2
+
+	// Упорядочиваем три элемента и ставим медиану в начало
+	if less(strs[mid], strs[start]) {
+		strs[mid], strs[start] = strs[start], strs[mid]
+	}
+	if less(strs[end], strs[start]) {
+		strs[end], strs[start] = strs[start], strs[end]
+	}
+	if less(strs[end], strs[mid]) {
+		strs[end], strs[mid] = strs[mid], strs[end]
+	}
+	strs[start], strs[mid] = strs[mid], strs[start]
+
+	pivot := strs[start]
+	left, right := start+1, end
+	for {
+		for left <= right && less(strs[left], pivot) {
+			left++
+		}
+		for left <= right && less(pivot, strs[right]) {
+			right--
+		}
+		if left >= right {
+			break
+		}
+
+		strs[left], strs[right] = strs[right], strs[left]
+		left++
+		right--
+	}
+
+	strs[start], strs[right] = strs[right], strs[start]
+
+	return right
+}
+
+// Сортировка вставками отрезка [start, end]
+func insertionSort[T any](strs []T, start, end int, less func(a, b T) bool) {
+	for i := start + 1; i <= end; i++ {
+		for j := i; j > start && less(strs[j], strs[j-1]); j-- {
+			strs[j], strs[j-1] = strs[j-1], strs[j]
+		}
+	}
+}
+
+// Пирамидальная сортировка
+func heapSort[T any](strs []T, less func(a, b T) bool) {
+	for i := len(strs)/2 - 1; i >= 0; i-- {
+		siftDown(strs, i, len(strs), less)
+	}
+
+	for end := len(strs) - 1; end > 0; end-- {
+		strs[0], strs[end] = strs[end], strs[0]
+		siftDown(strs, 0, end, less)
+	}
+}
+
+// Просеивание элемента root вниз по куче размера size
+func siftDown[T any](strs []T, root, size int, less func(a, b T) bool) {
+	for {
+		child := 2*root + 1
+		if child >= size {
+			return
+		}
+		if child+1 < size && less(strs[child], strs[child+1]) {
+			child++
+		}
+		if !less(strs[root], strs[child]) {
+			return
+		}
+
+		strs[root], strs[child] = strs[child], strs[root]
+		root = child
+	}
+}
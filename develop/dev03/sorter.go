@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Объём памяти под сортируемые строки по умолчанию
+const defaultBufferSize = 64 << 20
+
+// Ошибка несовместимых флагов
+var ErrIncompatibleFlags = errors.New("incompatible flags")
+
+/*
+Сортировщик строк с параметрами, заданными флагами.
+Работает с произвольными io.Reader и io.Writer и возвращает ошибки,
+поэтому может использоваться как библиотека.
+*/
+type Sorter struct {
+	// Куда выводятся предупреждения о неотсортированном вводе при слиянии, nil - никуда
+	Warnings io.Writer
+
+	flg  Flags
+	coll *collation
+}
+
+/*
+Создаёт сортировщик. Нулевые BufferSize и Parallel заменяются значениями
+по умолчанию, пустой TempDir означает системный каталог временных файлов.
+*/
+func NewSorter(flg Flags) (*Sorter, error) {
+	coll, err := newCollation(flg.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateFormat(flg.Format); err != nil {
+		return nil, err
+	}
+	if flg.Format == FormatText && len(flg.Fields) > 0 {
+		return nil, fmt.Errorf("%w: -field requires -format", ErrIncompatibleFlags)
+	}
+	if flg.Format == FormatJSONL && len(flg.Keys) > 0 {
+		return nil, fmt.Errorf("%w: -k with -format jsonl, use -field", ErrIncompatibleFlags)
+	}
+	if flg.Format != FormatText && (flg.MergeOnly || flg.Check || flg.QuietCheck) {
+		return nil, fmt.Errorf("%w: -format supports sorting only", ErrIncompatibleFlags)
+	}
+
+	if flg.BufferSize <= 0 {
+		flg.BufferSize = defaultBufferSize
+	}
+	if flg.Parallel < 1 {
+		flg.Parallel = 1
+	}
+
+	return &Sorter{flg: flg, coll: coll}, nil
+}
+
+/*
+Сортирует строки всех входных потоков вместе и записывает результат в w.
+Строки читаются из потоков по очереди, последняя строка потока
+может не заканчиваться переводом строки.
+С -format записи сортируются в памяти без ограничения -S.
+*/
+func (s *Sorter) Sort(w io.Writer, inputs ...io.Reader) error {
+	cmp := newComparison(s.flg, s.coll)
+
+	if s.flg.Format != FormatText {
+		return sortRecords(inputs, w, s.flg, cmp)
+	}
+
+	return externalSort(newLineReader(inputs...), w, s.flg, cmp)
+}
+
+/*
+Сливает уже отсортированные входные потоки в w без повторной сортировки.
+О первой строке, нарушающей порядок в каждом потоке, выводится предупреждение
+в Warnings, потоки при этом обозначаются номерами с 1.
+*/
+func (s *Sorter) Merge(w io.Writer, inputs ...io.Reader) error {
+	names := make([]string, len(inputs))
+	for i := range inputs {
+		names[i] = fmt.Sprintf("input %d", i+1)
+	}
+
+	return s.merge(w, names, inputs)
+}
+
+func (s *Sorter) merge(w io.Writer, names []string, inputs []io.Reader) error {
+	readers := make([]*bufio.Reader, len(inputs))
+	for i, r := range inputs {
+		readers[i] = bufio.NewReader(r)
+	}
+
+	warn := func(input int, disorder *DisorderError) {
+		if s.Warnings != nil {
+			fmt.Fprintf(s.Warnings, "sort: %s:%v\n", names[input], disorder)
+		}
+	}
+
+	cmp := newComparison(s.flg, s.coll)
+	return mergeReaders(readers, w, cmp.lines(), s.flg, cmp, warn)
+}
+
+// Проверяет, отсортированы ли строки r. Нарушение порядка возвращается как *DisorderError
+func (s *Sorter) Check(r io.Reader) error {
+	return checkSorted(newLineReader(r), s.flg, newComparison(s.flg, s.coll))
+}
+
+/*
+Сортирует входные файлы и записывает результат в файл output,
+с флагом -m сливает уже отсортированные файлы.
+Имя "-" означает стандартный ввод или вывод.
+Результат пишется во временный файл рядом с output и заменяет его
+только при успешном завершении, поэтому выходной файл может совпадать
+с одним из входных, а при ошибке остаётся нетронутым.
+*/
+func (s *Sorter) SortFiles(output string, inputs ...string) error {
+	readers := make([]io.Reader, 0, len(inputs))
+	for _, name := range inputs {
+		in, err := openInput(name)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		readers = append(readers, in)
+	}
+
+	process := s.Sort
+	if s.flg.MergeOnly {
+		process = func(w io.Writer, readers ...io.Reader) error {
+			return s.merge(w, inputs, readers)
+		}
+	}
+
+	if output == "-" {
+		return process(os.Stdout, readers...)
+	}
+
+	out, err := createOutput(output)
+	if err != nil {
+		return err
+	}
+
+	if err := process(out, readers...); err != nil {
+		out.Abort()
+		return err
+	}
+
+	return out.Commit()
+}
+
+// Проверяет, отсортирован ли файл, имя "-" означает стандартный ввод
+func (s *Sorter) CheckFile(input string) error {
+	in, err := openInput(input)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := s.Check(in); err != nil {
+		return fmt.Errorf("%s:%w", input, err)
+	}
+
+	return nil
+}
+
+// Открывает входной файл, имя "-" означает стандартный ввод
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(name)
+}
+
+/*
+Выходной файл, который записывается во временный файл в том же каталоге
+и заменяет name переименованием только после успешной записи,
+поэтому при ошибке сортировки прежнее содержимое файла сохраняется.
+Существующий файл, который не является обычным (например, /dev/null),
+открывается и пишется напрямую.
+*/
+type outputFile struct {
+	name string
+	file *os.File
+	temp bool
+}
+
+func createOutput(name string) (*outputFile, error) {
+	info, err := os.Stat(name)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err == nil && !info.Mode().IsRegular() {
+		file, err := os.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		return &outputFile{name: name, file: file}, nil
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".sort-*")
+	if err != nil {
+		return nil, err
+	}
+
+	// Временный файл создаётся с правами 0600, заменяемый файл сохраняет свои
+	mode := os.FileMode(0o644)
+	if info != nil {
+		mode = info.Mode().Perm()
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &outputFile{name: name, file: file, temp: true}, nil
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	return f.file.Write(p)
+}
+
+// Закрывает файл и заменяет им выходной
+func (f *outputFile) Commit() error {
+	if err := f.file.Close(); err != nil {
+		f.remove()
+		return err
+	}
+	if !f.temp {
+		return nil
+	}
+
+	if err := os.Rename(f.file.Name(), f.name); err != nil {
+		f.remove()
+		return err
+	}
+
+	return nil
+}
+
+// Закрывает и удаляет временный файл, выходной файл остаётся прежним
+func (f *outputFile) Abort() {
+	f.file.Close()
+	f.remove()
+}
+
+func (f *outputFile) remove() {
+	if f.temp {
+		os.Remove(f.file.Name())
+	}
+}
+
+// Читает строки из нескольких потоков по очереди
+type lineReader struct {
+	readers []*bufio.Reader
+}
+
+func newLineReader(inputs ...io.Reader) *lineReader {
+	readers := make([]*bufio.Reader, len(inputs))
+	for i, r := range inputs {
+		readers[i] = bufio.NewReader(r)
+	}
+
+	return &lineReader{readers: readers}
+}
+
+// Возвращает следующую строку или io.EOF, когда все потоки прочитаны
+func (lr *lineReader) ReadLine() (string, error) {
+	for len(lr.readers) > 0 {
+		str, err := readLine(lr.readers[0])
+		if err == io.EOF {
+			lr.readers = lr.readers[1:]
+			continue
+		}
+
+		return str, err
+	}
+
+	return "", io.EOF
+}
@@ -1,14 +1,13 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
-	"unicode"
 )
 
 /*
@@ -30,288 +29,489 @@ import (
 */
 
 type Flags struct {
-	Input        string // -in входной файл
-	Output       string // -out выходной файл
-	ColumnSort   int    // -k
-	NumericSort  bool   // -n
-	ReverseSort  bool   // -r
-	UniqueValues bool   // -u
+	Inputs       []string   // входные файлы, "-" - стандартный ввод
+	Output       string     // -o выходной файл, "-" - стандартный вывод
+	Keys         []KeySpec  // -k ключи сортировки
+	Format       string     // -format формат записей: csv, tsv, jsonl
+	Fields       []FieldKey // -field ключи сортировки записей по именам полей
+	Separator    string     // -t разделитель полей
+	NumericSort  bool       // -n
+	GeneralSort  bool       // -g
+	HumanSort    bool       // -h
+	VersionSort  bool       // -V
+	MonthSort    bool       // -M
+	IgnoreBlanks bool       // -b
+	FoldCase     bool       // -f
+	Dictionary   bool       // -d
+	Locale       string     // -locale локаль для сравнения строк, например ru_RU.UTF-8
+	ReverseSort  bool       // -r
+	UniqueValues bool       // -u
+	Check        bool       // -c проверить, отсортирован ли ввод
+	QuietCheck   bool       // -C проверить без вывода нарушающей порядок строки
+	Stable       bool       // -s не сравнивать строки целиком при равных ключах
+	MergeOnly    bool       // -m слить уже отсортированные файлы
+	BufferSize   int64      // -S объём памяти под сортируемые строки
+	TempDir      string     // -T каталог для временных файлов
+	Parallel     int        // -parallel число одновременно сортируемых частей
 }
 
 /*
-Переупорядочивает строки в массиве strs таким образом, чтобы строки,
-начинающиеся с заглавной буквы, следовали после строк, начинающихся с
-прописной буквы.
+Сравнивает строки без учёта регистра.
+При равенстве строки, начинающиеся со строчной буквы, идут раньше строк
+с заглавной буквой, как и в исходном порядке сортировки.
 */
-func fixUpperCase(strs []string) []string {
-	// Обход по всем строкам слайса strs
-	for i := 0; i < len(strs)-1; i++ {
-		// Текущая строка
-		currentStr := []rune(strs[i])
-		// Следующая строка
-		nextStr := []rune(strs[i+1])
-
-		/*
-			Если текущая строка strs[i] начинается с заглавной буквы, а следующая строка strs[i+1]
-			начинается с прописной буквы, то строки меняются местами.
-		*/
-		if unicode.ToLower(currentStr[0]) == unicode.ToLower(nextStr[0]) &&
-			unicode.IsUpper(currentStr[0]) &&
-			unicode.IsLower(nextStr[0]) {
-			temp := strs[i]
-			strs[i] = strs[i+1]
-			strs[i+1] = temp
-		}
+func compareText(a, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
 	}
 
-	return strs
+	return strings.Compare(b, a)
 }
 
 /*
-Сортировка слайса строк с использованием алгоритма быстрой сортировки
-start - индекс начала сортировки
-end - индекс конца сортировки
+Разбирает числовой префикс строки для -n: пробелы в начале, знак,
+цифры и дробную часть после точки. Возвращает знак и цифры целой и дробной
+частей без незначащих нулей. Символы после числа игнорируются,
+пустая строка или строка без числа в начале, как и в POSIX sort, считается нулём.
 */
-func quickSort(strs []string, start, end int, numericSort bool) []string {
-	if start < end {
-		// Выбор опорного элемента
-		pivot := strs[start]
-		left := start
-		right := end
-
-		for left < right {
-			// Если не числовая сортировка
-			if !numericSort {
-				for left < right && strings.ToLower(strs[right]) >= strings.ToLower(pivot) {
-					right--
-				}
-			} else {
-				p, err := strconv.Atoi(pivot)
-				if err != nil {
-					log.Fatalf("not a number: %s", pivot)
-				}
-				for left < right {
-					r, err := strconv.Atoi(strs[right])
-					if err != nil {
-						log.Fatalf("not a number: %s", strs[right])
-					}
-					if r >= p {
-						right--
-					} else {
-						break
-					}
-				}
-			}
+func numericPrefix(str string) (bool, string, string) {
+	str = strings.TrimLeft(str, " \t")
 
-			if left < right {
-				strs[left] = strs[right]
-				left++
-			}
+	negative := false
+	if str != "" && (str[0] == '-' || str[0] == '+') {
+		negative = str[0] == '-'
+		str = str[1:]
+	}
 
-			// Если не числовая сортировка
-			if !numericSort {
-				for left < right && strings.ToLower(strs[left]) <= strings.ToLower(pivot) {
-					left++
-				}
-			} else {
-				p, err := strconv.Atoi(pivot)
-				if err != nil {
-					log.Fatalf("not a number: %s", pivot)
-				}
-				for left < right {
-					l, err := strconv.Atoi(strs[left])
-					if err != nil {
-						log.Fatalf("not a number: %s", strs[left])
-					}
-					if l <= p {
-						left++
-					} else {
-						break
-					}
-				}
-			}
+	end := 0
+	for end < len(str) && isDigitByte(str[end]) {
+		end++
+	}
+	integer := strings.TrimLeft(str[:end], "0")
+
+	fraction := ""
+	if end < len(str) && str[end] == '.' {
+		end++
+		start := end
+		for end < len(str) && isDigitByte(str[end]) {
+			end++
+		}
+		fraction = strings.TrimRight(str[start:end], "0")
+	}
 
-			if left < right {
-				strs[right] = strs[left]
-				right--
-			}
+	// Минус ноль равен нулю
+	if integer == "" && fraction == "" {
+		negative = false
+	}
+
+	return negative, integer, fraction
+}
+
+/*
+Сравнивает числовые префиксы строк точно, без перевода в float64,
+поэтому длинные целые не теряют разрядов.
+*/
+func compareNumbers(a, b string) int {
+	negA, intA, fracA := numericPrefix(a)
+	negB, intB, fracB := numericPrefix(b)
+
+	if negA != negB {
+		if negA {
+			return -1
 		}
+		return 1
+	}
 
-		// Помещение опорного элемента на правильное место
-		strs[left] = pivot
+	c := len(intA) - len(intB)
+	if c == 0 {
+		c = strings.Compare(intA, intB)
+	}
+	if c == 0 {
+		c = strings.Compare(fracA, fracB)
+	}
 
-		// Рекурсивное применение quickSort к двум частям массива
-		strs = quickSort(strs, start, left-1, numericSort)
-		strs = quickSort(strs, left+1, end, numericSort)
+	if negA {
+		return -c
 	}
+	return c
+}
 
-	// Создание нового массива и копирование отсортированных строк
-	sortedStrs := make([]string, len(strs))
-	copy(sortedStrs, strs)
-	return sortedStrs
+// Множители суффиксов для -h
+var humanSuffixes = map[byte]float64{
+	'K': 1 << 10,
+	'k': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+	'T': 1 << 40,
+	'P': 1 << 50,
+	'E': 1 << 60,
 }
 
-// Удаляем дубликаты из слайса строк
-func onlyUnique(data []string) []string {
-	res := make([]string, 0, len(data))
-	m := make(map[string]struct{})
+/*
+Разбирает число с необязательным суффиксом, например 2K или 1.5G.
+Как и для -n, пустое значение, отсутствующее поле или строка,
+не являющаяся числом, считаются нулём.
+*/
+func parseHuman(str string) float64 {
+	number := strings.TrimSpace(str)
+	if number == "" {
+		return 0
+	}
 
-	for _, str := range data {
-		if _, ok := m[str]; !ok {
-			m[str] = struct{}{}
-			res = append(res, str)
-		}
+	multiplier := 1.0
+	if m, ok := humanSuffixes[number[len(number)-1]]; ok {
+		multiplier, number = m, number[:len(number)-1]
+	}
+
+	x, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0
 	}
 
-	return res
+	return x * multiplier
 }
 
-// Инвертирует порядок элементов в слайсе
-func reversed(data []string) []string {
-	for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
-		data[i], data[j] = data[j], data[i]
+// Сравнивает строки как числа с суффиксами: 2K < 1G
+func compareHuman(a, b string) int {
+	x, y := parseHuman(a), parseHuman(b)
+
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
 	}
 
-	return data
+	return 0
+}
+
+var months = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
 }
 
-// Получение ключей из карты в виде массива строк.
-func getKeysOfMap(m map[string]string) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+/*
+Возвращает номер месяца по первым трём буквам строки без учёта регистра.
+Строки, не начинающиеся с названия месяца, получают 0 и идут раньше января.
+*/
+func parseMonth(str string) int {
+	str = strings.TrimLeft(str, " \t")
+	if len(str) < 3 {
+		return 0
 	}
 
-	return keys
+	return months[strings.ToUpper(str[:3])]
 }
 
-// Сортирует массив строк strs по заданному столбцу
-func sortByColumn(strs []string, flg Flags) []string {
-	sourceMap := make(map[string]string)
-	for _, str := range strs {
-		columns := strings.Split(str, " ")
-		if len(columns) > flg.ColumnSort {
-			sourceMap[columns[flg.ColumnSort]] = str
-			continue
-		}
-		sourceMap[columns[0]] = str
+// Сравнивает строки по названию месяца
+func compareMonths(a, b string) int {
+	return parseMonth(a) - parseMonth(b)
+}
+
+// Сравнивает значения ключа с учётом модификаторов, coll - порядок локали или nil
+func compareKey(a, b string, opt KeyOptions, coll *collation) int {
+	if opt.StartBlanks {
+		a, b = strings.TrimLeft(a, " \t"), strings.TrimLeft(b, " \t")
+	}
+
+	var c int
+	switch {
+	case opt.General:
+		c = compareGeneral(a, b)
+	case opt.Human:
+		c = compareHuman(a, b)
+	case opt.Version:
+		c = compareVersions(a, b)
+	case opt.Month:
+		c = compareMonths(a, b)
+	case opt.Numeric:
+		c = compareNumbers(a, b)
+	default:
+		c = compareString(a, b, opt, coll)
+	}
+
+	if opt.Reverse {
+		return -c
+	}
+	return c
+}
+
+// Модификаторы, заданные глобальными флагами
+func globalOptions(flg Flags) KeyOptions {
+	return KeyOptions{
+		Numeric:     flg.NumericSort,
+		General:     flg.GeneralSort,
+		Human:       flg.HumanSort,
+		Version:     flg.VersionSort,
+		Month:       flg.MonthSort,
+		Reverse:     flg.ReverseSort,
+		StartBlanks: flg.IgnoreBlanks,
+		EndBlanks:   flg.IgnoreBlanks,
+		Fold:        flg.FoldCase,
+		Dictionary:  flg.Dictionary,
 	}
+}
 
-	keysToBeSorted := getKeysOfMap(sourceMap)
-	quickSort(keysToBeSorted, 0, len(keysToBeSorted)-1, flg.NumericSort)
-	sortedStrs := make([]string, len(strs))
+// Сравнение строк по заданным флагам
+type comparison struct {
+	flg  Flags
+	coll *collation
+	keys []KeySpec // ключи -k, ключи без модификаторов наследуют глобальные флаги
+}
 
-	for i, key := range keysToBeSorted {
-		sortedStrs[i] = sourceMap[key]
+func newComparison(flg Flags, coll *collation) *comparison {
+	keys := make([]KeySpec, len(flg.Keys))
+	for i, key := range flg.Keys {
+		if !key.Options.isSet() {
+			key.Options = globalOptions(flg)
+		}
+		keys[i] = key
 	}
 
-	return sortedStrs
+	return &comparison{flg: flg, coll: coll, keys: keys}
 }
 
-func Sort(flg Flags) {
-	// Считываем строки из входного файла
-	strs := readFile(flg.Input)
+/*
+Строка вместе с её ключами -k. Ключи выделяются один раз при чтении строки,
+а не при каждом из O(n log n) сравнений.
+*/
+type keyedLine struct {
+	text string
+	keys []string
+}
+
+// Разбивает строку на поля и выделяет из неё ключи -k
+func (cmp *comparison) keyed(str string) keyedLine {
+	if len(cmp.keys) == 0 {
+		return keyedLine{text: str}
+	}
 
-	// Создаем пустой слайс result
-	result := make([]string, len(strs))
-	// копируем строки из strs в result
-	copy(result, strs)
+	return cmp.keyedFields(str, fieldSpans(str, cmp.flg.Separator))
+}
 
-	if flg.ColumnSort >= 0 {
-		// Сортируем массив по заданному столбцу
-		result = sortByColumn(result, flg)
-		// Решаем проблему с заглавными буквами
-		result = fixUpperCase(result)
-	} else {
-		// Сортировка всего слайса
-		result = quickSort(result, 0, len(result)-1, false)
-		// Решаем проблему с заглавными буквами
-		result = fixUpperCase(result)
+// Выделяет ключи -k из строки, уже разбитой на поля fields
+func (cmp *comparison) keyedFields(str string, fields []span) keyedLine {
+	if len(cmp.keys) == 0 {
+		return keyedLine{text: str}
 	}
 
-	if flg.UniqueValues {
-		// Сортируем всего слайса
-		result = quickSort(result, 0, len(result)-1, false)
-		// Решаем проблему с заглавными буквами
-		result = fixUpperCase(result)
-		// Оставляем только уникальные строки
-		result = onlyUnique(result)
+	keys := make([]string, len(cmp.keys))
+	for i, key := range cmp.keys {
+		keys[i] = extractKey(str, fields, key)
 	}
 
-	if flg.ReverseSort {
-		// Инвертируем порядок строк
-		result = reversed(result)
+	return keyedLine{text: str, keys: keys}
+}
+
+// Сравнивает значения ключа с учётом порядка локали
+func (cmp *comparison) key(a, b string, opt KeyOptions) int {
+	return compareKey(a, b, opt, cmp.coll)
+}
+
+/*
+Формирует функцию сравнения строк только по ключам.
+Без ключей вся строка сравнивается по глобальным флагам.
+*/
+func (cmp *comparison) compareKeys() func(a, b keyedLine) int {
+	if len(cmp.keys) == 0 {
+		global := globalOptions(cmp.flg)
+
+		return func(a, b keyedLine) int {
+			return cmp.key(a.text, b.text, global)
+		}
 	}
 
-	// Записываем строки в выходной файл
-	writeToFile(flg.Output, result)
+	return func(a, b keyedLine) int {
+		for i, key := range cmp.keys {
+			if c := cmp.key(a.keys[i], b.keys[i], key.Options); c != 0 {
+				return c
+			}
+		}
+
+		return 0
+	}
 }
 
-// Чтение из файла
-func readFile(fileName string) []string {
-	var rows []string
+/*
+Формирует функцию сравнения строк по заданным флагам.
+При равенстве всех ключей строки сравниваются целиком,
+с флагом -s строки с равными ключами остаются в исходном порядке.
+С флагом -u повторяющиеся строки при равных ключах идут подряд,
+чтобы при выводе можно было оставить только первую из них.
+*/
+func (cmp *comparison) lines() func(a, b keyedLine) int {
+	compare := cmp.compareKeys()
+	if cmp.flg.UniqueValues {
+		compare = thenBy(compare, cmp.unique())
+	}
+	if !cmp.flg.Stable {
+		compare = thenBy(compare, cmp.lastResort())
+	}
 
-	file, err := os.Open(fileName)
-	defer func(file *os.File) {
-		if err := file.Close(); err != nil {
-			log.Fatalf("failed to close file: %v", err)
+	return compare
+}
+
+// Сравнивает строки по ключам функцией first, при равенстве - целиком функцией second
+func thenBy(first func(a, b keyedLine) int, second func(a, b string) int) func(a, b keyedLine) int {
+	return func(a, b keyedLine) int {
+		if c := first(a, b); c != 0 {
+			return c
 		}
-	}(file)
 
-	if err != nil {
-		log.Fatalf("failed to open file: %v", err)
+		return second(a.text, b.text)
 	}
+}
 
-	sc := bufio.NewScanner(file)
-	for sc.Scan() {
-		rows = append(rows, sc.Text())
+/*
+Сравнение строк целиком для -u независимо от ключей -k.
+Повторами считаются строки, равные как текст с учётом -f, -d, -b и локали,
+без этих флагов - одинаковые строки, как в исходной реализации.
+*/
+func (cmp *comparison) unique() func(a, b string) int {
+	opt := KeyOptions{
+		Fold:        cmp.flg.FoldCase,
+		Dictionary:  cmp.flg.Dictionary,
+		StartBlanks: cmp.flg.IgnoreBlanks,
+		Reverse:     cmp.flg.ReverseSort,
 	}
 
-	return rows
+	return func(a, b string) int {
+		return cmp.key(a, b, opt)
+	}
 }
 
-// Запись в файл
-func writeToFile(fileName string, strs []string) {
-	file, err := os.Create(fileName)
-	if err != nil {
-		log.Fatalf("failed to create file: %v", err)
+// Сравнение строк целиком при равенстве всех ключей с учётом -r
+func (cmp *comparison) lastResort() func(a, b string) int {
+	compare := compareText
+	if coll := cmp.coll; coll != nil {
+		compare = func(a, b string) int {
+			if c := coll.compare(a, b, false); c != 0 {
+				return c
+			}
+			// Строки, неразличимые для локали, упорядочиваются побайтово
+			return strings.Compare(a, b)
+		}
 	}
-	defer func(file *os.File) {
-		if err := file.Close(); err != nil {
-			log.Fatalf("failed to close file: %v", err)
+
+	if cmp.flg.ReverseSort {
+		return func(a, b string) int {
+			return compare(b, a)
 		}
-	}(file)
+	}
+	return compare
+}
 
-	for _, str := range strs {
-		if _, err := fmt.Fprintln(file, str); err != nil {
-			log.Fatalf("failed to write to file: %v", err)
+// Флаги со значением, которое может быть записано слитно: -k2,2 или -t:
+var shortValueFlags = []string{"-k", "-t", "-o", "-S", "-T"}
+
+// Разделяет слитно записанные флаги со значением на флаг и значение
+func splitShortFlags(args []string) []string {
+	result := make([]string, 0, len(args))
+
+	for i, arg := range args {
+		// После "--" идут только имена файлов
+		if arg == "--" {
+			return append(result, args[i:]...)
+		}
+
+		split := false
+		for _, name := range shortValueFlags {
+			if len(arg) > len(name) && strings.HasPrefix(arg, name) && arg[len(name)] != '=' {
+				result = append(result, name, arg[len(name):])
+				split = true
+				break
+			}
+		}
+
+		if !split {
+			result = append(result, arg)
 		}
 	}
+
+	return result
 }
 
 // Парсит аргументы командной строки
 func parseFlags() Flags {
-	columnSort := flag.Int("k", -1, "column number for sorting")
+	var keys keyList
+	flag.Var(&keys, "k", "sort key POS1[,POS2], POS is F[.C][OPTS], may be repeated")
+	separator := flag.String("t", "", "field separator")
+	format := flag.String("format", "", "record format: csv, tsv or jsonl")
+	var fields fieldList
+	flag.Var(&fields, "field", "record sort key NAME[:OPTS] for -format, e.g. .user.id:n, may be repeated")
+	output := flag.String("o", "", "write result to file instead of standard output")
 	numericSort := flag.Bool("n", false, "sort numerically")
+	generalSort := flag.Bool("g", false, "sort by general numeric value, e.g. 1.5e3")
+	humanSort := flag.Bool("h", false, "sort human readable numbers, e.g. 2K, 1G")
+	versionSort := flag.Bool("V", false, "natural sort of version numbers, e.g. file2 < file10")
+	monthSort := flag.Bool("M", false, "sort by month name")
+	ignoreBlanks := flag.Bool("b", false, "ignore leading blanks")
+	foldCase := flag.Bool("f", false, "fold lower case to upper case characters")
+	dictionary := flag.Bool("d", false, "consider only blanks and alphanumeric characters")
+	locale := flag.String("locale", "", "collate strings by the rules of locale, e.g. ru_RU.UTF-8")
 	reverseSort := flag.Bool("r", false, "sort in reverse order")
-	uniqueValues := flag.Bool("u", false, "show only unique values")
+	uniqueValues := flag.Bool("u", false, "output only the first of repeated lines")
+	check := flag.Bool("c", false, "check for sorted input, report first disorder")
+	quietCheck := flag.Bool("C", false, "check for sorted input, do not report disorder")
+	mergeOnly := flag.Bool("m", false, "merge already sorted files, do not sort")
+	stable := flag.Bool("s", false, "stable sort, keep input order of lines with equal keys")
+	bufferSize := flag.String("S", "64M", "memory buffer size, e.g. 512K, 64M, 1G")
+	tempDir := flag.String("T", os.TempDir(), "directory for temporary files")
+	parallel := flag.Int("parallel", 1, "number of partitions sorted concurrently")
+
+	if err := flag.CommandLine.Parse(splitShortFlags(os.Args[1:])); err != nil {
+		log.Fatal(err)
+	}
+
+	inputs, out := flag.Args(), *output
 
-	flag.Parse()
+	checkMode := *check || *quietCheck
+	if checkMode && len(inputs) > 1 {
+		log.Fatal("usage: go task.go -c [input-file]")
+	}
 
-	if flag.NArg() != 2 {
-		log.Fatal("usage: go task.go input-file output-file")
+	// Как и в исходной реализации, без -o и -m два аргумента - входной и выходной файлы,
+	// в остальных случаях все аргументы - входные файлы, а выходной задаётся через -o
+	if out == "" && !*mergeOnly && !checkMode && len(inputs) == 2 {
+		inputs, out = inputs[:1], inputs[1]
+	}
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	if out == "" {
+		out = "-"
 	}
 
-	in := flag.Arg(0)
-	out := flag.Arg(1)
+	size, err := parseSize(*bufferSize)
+	if err != nil {
+		log.Fatalf("invalid buffer size: %v", err)
+	}
 
 	flg := Flags{
-		Input:        in,
+		Inputs:       inputs,
 		Output:       out,
-		ColumnSort:   *columnSort,
+		Keys:         keys,
+		Format:       *format,
+		Fields:       fields,
+		Separator:    *separator,
 		NumericSort:  *numericSort,
+		GeneralSort:  *generalSort,
+		HumanSort:    *humanSort,
+		VersionSort:  *versionSort,
+		MonthSort:    *monthSort,
+		IgnoreBlanks: *ignoreBlanks,
+		FoldCase:     *foldCase,
+		Dictionary:   *dictionary,
+		Locale:       *locale,
 		ReverseSort:  *reverseSort,
 		UniqueValues: *uniqueValues,
+		Check:        *check,
+		QuietCheck:   *quietCheck,
+		Stable:       *stable,
+		MergeOnly:    *mergeOnly,
+		BufferSize:   size,
+		TempDir:      *tempDir,
+		Parallel:     *parallel,
 	}
 
 	return flg
@@ -319,5 +519,31 @@ func parseFlags() Flags {
 
 func main() {
 	flg := parseFlags()
-	Sort(flg)
+
+	sorter, err := NewSorter(flg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sorter.Warnings = os.Stderr
+
+	if flg.Check || flg.QuietCheck {
+		err := sorter.CheckFile(flg.Inputs[0])
+
+		// Нарушение порядка - не ошибка выполнения, а результат проверки
+		var disorder *DisorderError
+		if errors.As(err, &disorder) {
+			if !flg.QuietCheck {
+				fmt.Fprintf(os.Stderr, "sort: %v\n", err)
+			}
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Fatalf("failed to check: %v", err)
+		}
+		return
+	}
+
+	if err := sorter.SortFiles(flg.Output, flg.Inputs...); err != nil {
+		log.Fatalf("failed to sort: %v", err)
+	}
 }
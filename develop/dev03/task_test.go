@@ -0,0 +1,1094 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Сортирует строки input внешней сортировкой с параметрами flg
+func sortLines(t *testing.T, input []string, flg Flags) []string {
+	t.Helper()
+
+	sorter, err := NewSorter(flg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out strings.Builder
+	if err := sorter.Sort(&out, strings.NewReader(strings.Join(input, "\n"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := strings.Split(out.String(), "\n")
+	return result[:len(result)-1]
+}
+
+func linesEqual(expected, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+
+	for i := range expected {
+		if expected[i] != actual[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestExternalSort(t *testing.T) {
+	input := []string{"House", "proxy", "Apple", "QIWI", "apple", "Google", "bing", "Yandex", "QIWI"}
+
+	tests := []struct {
+		name     string
+		input    []string
+		flg      Flags
+		expected []string
+	}{
+		{
+			name:     "default",
+			input:    input,
+			flg:      Flags{},
+			expected: []string{"apple", "Apple", "bing", "Google", "House", "proxy", "QIWI", "QIWI", "Yandex"},
+		},
+		{
+			name:     "reverse",
+			input:    input,
+			flg:      Flags{ReverseSort: true},
+			expected: []string{"Yandex", "QIWI", "QIWI", "proxy", "House", "Google", "bing", "Apple", "apple"},
+		},
+		{
+			name:     "unique",
+			input:    input,
+			flg:      Flags{UniqueValues: true},
+			expected: []string{"apple", "Apple", "bing", "Google", "House", "proxy", "QIWI", "Yandex"},
+		},
+		{
+			name:     "numeric",
+			input:    []string{"10", "9", "-3", "100"},
+			flg:      Flags{NumericSort: true},
+			expected: []string{"-3", "9", "10", "100"},
+		},
+		{
+			name:     "numeric prefix",
+			input:    []string{"10", "1.5x", "007", "-0", "1e3", "1.25", "123456789012345678901", "123456789012345678900"},
+			flg:      Flags{NumericSort: true},
+			expected: []string{"-0", "1e3", "1.25", "1.5x", "007", "10", "123456789012345678900", "123456789012345678901"},
+		},
+		{
+			name:     "numeric missing field",
+			input:    []string{"a 2", "b", "", "c -1", "d x"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2n")}},
+			expected: []string{"c -1", "", "b", "d x", "a 2"},
+		},
+		{
+			name:     "column",
+			input:    []string{"b 3", "a 1", "c 2", "A 5", "d 1"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2")}, NumericSort: true},
+			expected: []string{"a 1", "d 1", "c 2", "b 3", "A 5"},
+		},
+		{
+			name:     "several keys",
+			input:    []string{"x 2 b", "y 10 a", "z 2 a", "w 10 a"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2nr"), mustParseKey("3,3")}},
+			expected: []string{"w 10 a", "y 10 a", "z 2 a", "x 2 b"},
+		},
+		{
+			name:     "separator",
+			input:    []string{"b:2", "a:3", "c:1"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2")}, Separator: ":"},
+			expected: []string{"c:1", "b:2", "a:3"},
+		},
+		{
+			name:     "stable",
+			input:    []string{"b 1", "a 2", "c 1", "B 1"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2")}, Stable: true},
+			expected: []string{"b 1", "c 1", "B 1", "a 2"},
+		},
+		{
+			name:     "stable several keys",
+			input:    []string{"x 2 b", "y 1 a", "z 2 a", "w 1 a", "v 2 b"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2n"), mustParseKey("3,3")}, Stable: true},
+			expected: []string{"y 1 a", "w 1 a", "z 2 a", "x 2 b", "v 2 b"},
+		},
+		{
+			name:     "stable reverse",
+			input:    []string{"b 1", "a 2", "c 1"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2")}, Stable: true, ReverseSort: true},
+			expected: []string{"a 2", "b 1", "c 1"},
+		},
+		{
+			name:     "month",
+			input:    []string{"Mar", "jan", "dec", "foo", " feb"},
+			flg:      Flags{MonthSort: true},
+			expected: []string{"foo", "jan", " feb", "Mar", "dec"},
+		},
+		{
+			name:     "month key reverse",
+			input:    []string{"1 Mar", "2 Jan", "3 Dec"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2")}, MonthSort: true, ReverseSort: true},
+			expected: []string{"3 Dec", "1 Mar", "2 Jan"},
+		},
+		{
+			name:     "human",
+			input:    []string{"1G", "2K", "512", "1.5M", "3K"},
+			flg:      Flags{HumanSort: true},
+			expected: []string{"512", "2K", "3K", "1.5M", "1G"},
+		},
+		{
+			name:     "human empty",
+			input:    []string{"a 2K", "b", "", "c 512", "d  "},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2h")}},
+			expected: []string{"", "b", "d  ", "c 512", "a 2K"},
+		},
+		{
+			name:     "human key",
+			input:    []string{"a 10M", "b 1G", "c 900K"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2hr")}},
+			expected: []string{"b 1G", "a 10M", "c 900K"},
+		},
+		{
+			name:     "ignore blanks",
+			input:    []string{"  c", "b", " a"},
+			flg:      Flags{IgnoreBlanks: true},
+			expected: []string{" a", "b", "  c"},
+		},
+		{
+			name:     "ignore blanks key",
+			input:    []string{"x   c", "y b", "z  a"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2")}, IgnoreBlanks: true},
+			expected: []string{"z  a", "y b", "x   c"},
+		},
+		{
+			name:     "general numeric",
+			input:    []string{"1e3", "abc", "-2.5", "NaN", " 7", "+inf", "0.5x", "-INF"},
+			flg:      Flags{GeneralSort: true},
+			expected: []string{"abc", "NaN", "-INF", "-2.5", "0.5x", " 7", "1e3", "+inf"},
+		},
+		{
+			name:     "general numeric key reverse",
+			input:    []string{"a 1.5", "b -", "c 2e-1"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2gr")}},
+			expected: []string{"a 1.5", "c 2e-1", "b -"},
+		},
+		{
+			name:     "version",
+			input:    []string{"file10", "file2", "1.2.10", "1.2.9", "1.0", "1.0~rc1", "file02b"},
+			flg:      Flags{VersionSort: true},
+			expected: []string{"1.0~rc1", "1.0", "1.2.9", "1.2.10", "file2", "file02b", "file10"},
+		},
+		{
+			name:     "locale",
+			input:    []string{"ёж", "Ель", "ель", "еж", "жук", "apple", "Яблоко", "арбуз", "Ёлка", "елка"},
+			flg:      Flags{Locale: "ru_RU.UTF-8"},
+			expected: []string{"apple", "арбуз", "еж", "ёж", "елка", "Ёлка", "ель", "Ель", "жук", "Яблоко"},
+		},
+		{
+			name:     "locale key reverse",
+			input:    []string{"1 Ёлка", "2 арбуз", "3 жук"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2r")}, Locale: "ru"},
+			expected: []string{"3 жук", "1 Ёлка", "2 арбуз"},
+		},
+		{
+			name:     "locale fold unique",
+			input:    []string{"Ель", "ель", "ЕЛЬ", "ёж"},
+			flg:      Flags{Locale: "ru_RU", FoldCase: true, UniqueValues: true},
+			expected: []string{"ёж", "ель"},
+		},
+		{
+			name:     "fold unique",
+			input:    []string{"b", "Apple", "apple", "B"},
+			flg:      Flags{FoldCase: true, UniqueValues: true},
+			expected: []string{"apple", "b"},
+		},
+		{
+			name:     "dictionary",
+			input:    []string{"c-d", "(b)", "a.z", "ca"},
+			flg:      Flags{Dictionary: true},
+			expected: []string{"a.z", "(b)", "ca", "c-d"},
+		},
+		{
+			name:     "unique with key",
+			input:    []string{"b 1", "a 2", "c 1", "b 1", "a 2"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2")}, UniqueValues: true},
+			expected: []string{"b 1", "c 1", "a 2"},
+		},
+		{
+			name:     "unique stable",
+			input:    []string{"b 1", "c 1", "a 2", "b 1"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2")}, UniqueValues: true, Stable: true},
+			expected: []string{"b 1", "c 1", "a 2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Весь ввод в памяти
+			test.flg.BufferSize = 1 << 20
+			inMemory := sortLines(t, test.input, test.flg)
+			if !linesEqual(test.expected, inMemory) {
+				t.Errorf("expected %v, got %v", test.expected, inMemory)
+			}
+
+			// Сортировка частей в нескольких горутинах
+			test.flg.Parallel = 3
+			parallel := sortLines(t, test.input, test.flg)
+			if !linesEqual(test.expected, parallel) {
+				t.Errorf("expected %v in parallel, got %v", test.expected, parallel)
+			}
+
+			// Каждая строка в отдельном временном файле
+			test.flg.BufferSize = 1
+			test.flg.TempDir = t.TempDir()
+			merged := sortLines(t, test.input, test.flg)
+			if !linesEqual(test.expected, merged) {
+				t.Errorf("expected %v after merge, got %v", test.expected, merged)
+			}
+
+			// Временные файлы удаляются после слияния
+			entries, err := os.ReadDir(test.flg.TempDir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("expected temporary files to be removed, got %d", len(entries))
+			}
+		})
+	}
+}
+
+// Строки с равными ключами не теряются ни при каком режиме сортировки
+func TestSortKeepsAllLines(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	input := make([]string, 500)
+	for i := range input {
+		input[i] = fmt.Sprintf("%d %d", rnd.Intn(5), i)
+	}
+
+	expected := append([]string(nil), input...)
+	sort.Strings(expected)
+
+	for _, flg := range []Flags{
+		{Keys: []KeySpec{mustParseKey("1,1")}, BufferSize: 1 << 20},
+		{Keys: []KeySpec{mustParseKey("1,1")}, Stable: true, BufferSize: 1 << 20},
+		{Keys: []KeySpec{mustParseKey("1,1")}, Stable: true, Parallel: 3, BufferSize: 1 << 20},
+		{Keys: []KeySpec{mustParseKey("1,1")}, Stable: true, BufferSize: 1 << 10, TempDir: t.TempDir()},
+	} {
+		result := sortLines(t, input, flg)
+
+		sorted := append([]string(nil), result...)
+		sort.Strings(sorted)
+		if !linesEqual(expected, sorted) {
+			t.Errorf("flags %+v: expected %d lines, got %d", flg, len(input), len(result))
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "file2", b: "file10", expected: -1},
+		{a: "1.2.10", b: "1.2.9", expected: 1},
+		{a: "1.0~rc1", b: "1.0", expected: -1},
+		{a: "1.0", b: "1.0.1", expected: -1},
+		{a: "v1.010", b: "v1.10", expected: 0},
+		{a: "1.0a", b: "1.0-", expected: -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			c := compareVersions(test.a, test.b)
+			if c > 0 {
+				c = 1
+			} else if c < 0 {
+				c = -1
+			}
+
+			if c != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, c)
+			}
+		})
+	}
+}
+
+func TestSorter(t *testing.T) {
+	t.Run("multiple inputs", func(t *testing.T) {
+		sorter, err := NewSorter(Flags{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var out strings.Builder
+		err = sorter.Sort(&out, strings.NewReader("c\na"), strings.NewReader("b\n"), strings.NewReader(""))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if expected := "a\nb\nc\n"; out.String() != expected {
+			t.Errorf("expected %q, got %q", expected, out.String())
+		}
+	})
+
+	t.Run("not a number", func(t *testing.T) {
+		sorter, err := NewSorter(Flags{HumanSort: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var out strings.Builder
+		if err := sorter.Sort(&out, strings.NewReader("2G\nsize\n1K\n")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := "size\n1K\n2G\n"; out.String() != expected {
+			t.Errorf("expected %q, got %q", expected, out.String())
+		}
+
+		var disorder *DisorderError
+		err = sorter.Check(strings.NewReader("1\nx"))
+		if !errors.As(err, &disorder) || disorder.Line != 2 {
+			t.Errorf("expected disorder at line 2, got %v", err)
+		}
+	})
+
+	t.Run("invalid locale", func(t *testing.T) {
+		if _, err := NewSorter(Flags{Locale: "not a locale"}); !errors.Is(err, ErrInvalidLocale) {
+			t.Errorf("expected ErrInvalidLocale, got %v", err)
+		}
+	})
+
+	t.Run("sort file in place", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "data.txt")
+		if err := os.WriteFile(name, []byte("b\nc\na\n"), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		sorter, err := NewSorter(Flags{BufferSize: 1, TempDir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := sorter.SortFiles(name, name); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := "a\nb\nc\n"; string(data) != expected {
+			t.Errorf("expected %q, got %q", expected, data)
+		}
+
+		if err := sorter.CheckFile(name); err != nil {
+			t.Errorf("expected sorted file, got %v", err)
+		}
+
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.Mode().Perm() != 0o644 {
+			t.Errorf("expected mode 0644, got %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("failed sort keeps output", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "data.txt")
+		input := "{\"k\": 2}\nnot json\n"
+		if err := os.WriteFile(name, []byte(input), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		sorter, err := NewSorter(Flags{Format: FormatJSONL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := sorter.SortFiles(name, name); err == nil {
+			t.Fatal("expected error")
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(data) != input {
+			t.Errorf("expected %q, got %q", input, data)
+		}
+
+		// Временный выходной файл удаляется
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) != 1 {
+			t.Errorf("expected only data.txt in %s, got %v, %v", dir, entries, err)
+		}
+	})
+}
+
+// Разбирает ключ по полю, при ошибке вызывает панику
+func mustParseFieldKey(str string) FieldKey {
+	key, err := ParseFieldKey(str)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+func TestSortRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   []string
+		flg      Flags
+		expected string
+	}{
+		{
+			name:     "csv by name",
+			inputs:   []string{"name,city\n\"Smith, John\",Moscow\nadam,\"New\nYork\"\n"},
+			flg:      Flags{Format: FormatCSV, Fields: []FieldKey{mustParseFieldKey("name")}},
+			expected: "name,city\nadam,\"New\nYork\"\n\"Smith, John\",Moscow\n",
+		},
+		{
+			name:     "csv numeric reverse",
+			inputs:   []string{"id,size\n1,2K\n2,1G\n3,512\n"},
+			flg:      Flags{Format: FormatCSV, Fields: []FieldKey{mustParseFieldKey("size:hr")}},
+			expected: "id,size\n2,1G\n1,2K\n3,512\n",
+		},
+		{
+			name:     "csv several inputs",
+			inputs:   []string{"a,b\n2,x\n", "a,b\n1,y\n", ""},
+			flg:      Flags{Format: FormatCSV, Fields: []FieldKey{mustParseFieldKey("a:n")}},
+			expected: "a,b\n1,y\n2,x\n",
+		},
+		{
+			name:     "tsv missing column",
+			inputs:   []string{"k\tv\nb\t2\na\nc\t1\n"},
+			flg:      Flags{Format: FormatTSV, Fields: []FieldKey{mustParseFieldKey("v")}},
+			expected: "k\tv\na\nc\t1\nb\t2\n",
+		},
+		{
+			name:     "csv key column",
+			inputs:   []string{"x,y\n1,b c\n2,a d\n"},
+			flg:      Flags{Format: FormatCSV, Keys: []KeySpec{mustParseKey("2")}},
+			expected: "x,y\n2,a d\n1,b c\n",
+		},
+		{
+			name:     "tsv key columns",
+			inputs:   []string{"a\tb\tc\n1\tx\t10\n2\tx\t9\n3\ty\t1\n"},
+			flg:      Flags{Format: FormatTSV, Keys: []KeySpec{mustParseKey("2,2r"), mustParseKey("3n")}},
+			expected: "a\tb\tc\n3\ty\t1\n2\tx\t9\n1\tx\t10\n",
+		},
+		{
+			name:     "tsv quotes",
+			inputs:   []string{"item\tprice\nTV 5\" screen\t10\n\"TV\"\t2\n\"a\tb\"\t5\n"},
+			flg:      Flags{Format: FormatTSV, Fields: []FieldKey{mustParseFieldKey("price:n")}},
+			expected: "item\tprice\n\"a\tb\"\t5\n\"TV\"\t2\nTV 5\" screen\t10\n",
+		},
+		{
+			name: "jsonl typed",
+			inputs: []string{
+				`{"user":{"id":10},"n":"b"}` + "\n" +
+					`{"user":{"id":9},"n":"a"}` + "\n\n" +
+					`{"user":{"id":"x"}}` + "\n" +
+					`{"user":{}}` + "\n" +
+					`{"user":{"id":null}}`,
+			},
+			flg: Flags{Format: FormatJSONL, Fields: []FieldKey{mustParseFieldKey(".user.id")}},
+			expected: `{"user":{}}` + "\n" +
+				`{"user":{"id":null}}` + "\n" +
+				`{"user":{"id":9},"n":"a"}` + "\n" +
+				`{"user":{"id":10},"n":"b"}` + "\n" +
+				`{"user":{"id":"x"}}` + "\n",
+		},
+		{
+			name:     "jsonl numbers",
+			inputs:   []string{`{"p":1e3}` + "\n" + `{"p":1.5}` + "\n" + `{"p":-2}` + "\n" + `{"p":1}`},
+			flg:      Flags{Format: FormatJSONL, Fields: []FieldKey{mustParseFieldKey(".p:n")}},
+			expected: `{"p":-2}` + "\n" + `{"p":1}` + "\n" + `{"p":1.5}` + "\n" + `{"p":1e3}` + "\n",
+		},
+		{
+			name:     "jsonl human not a number",
+			inputs:   []string{`{"a":"2K"}` + "\n" + `{"a":"x"}` + "\n" + `{"a":1}`},
+			flg:      Flags{Format: FormatJSONL, Fields: []FieldKey{mustParseFieldKey(".a:h")}},
+			expected: `{"a":"x"}` + "\n" + `{"a":1}` + "\n" + `{"a":"2K"}` + "\n",
+		},
+		{
+			name:     "jsonl array index reverse",
+			inputs:   []string{`{"v":[1,"b"]}` + "\n" + `{"v":[2,"a"]}` + "\n" + `{"v":[3,"b"]}`},
+			flg:      Flags{Format: FormatJSONL, Fields: []FieldKey{mustParseFieldKey(".v.1"), mustParseFieldKey(".v.0:r")}},
+			expected: `{"v":[2,"a"]}` + "\n" + `{"v":[3,"b"]}` + "\n" + `{"v":[1,"b"]}` + "\n",
+		},
+		{
+			name:     "jsonl unique",
+			inputs:   []string{`{"k":true,"i":2}` + "\n" + `{"k":false}` + "\n" + `{"k":true,"i":1}` + "\n" + `{"k":true,"i":2}`},
+			flg:      Flags{Format: FormatJSONL, Fields: []FieldKey{mustParseFieldKey(".k")}, UniqueValues: true, Stable: true},
+			expected: `{"k":false}` + "\n" + `{"k":true,"i":1}` + "\n" + `{"k":true,"i":2}` + "\n",
+		},
+		{
+			name:     "csv unique",
+			inputs:   []string{"a,b\n1,x\n2,x\n1,x\n", "a,b\n1,x\n"},
+			flg:      Flags{Format: FormatCSV, Fields: []FieldKey{mustParseFieldKey("b")}, UniqueValues: true},
+			expected: "a,b\n1,x\n2,x\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorter, err := NewSorter(test.flg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			inputs := make([]io.Reader, len(test.inputs))
+			for i, input := range test.inputs {
+				inputs[i] = strings.NewReader(input)
+			}
+
+			var out strings.Builder
+			if err := sorter.Sort(&out, inputs...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestSortRecordsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   []string
+		flg      Flags
+		expected error
+	}{
+		{
+			name:     "unknown format",
+			flg:      Flags{Format: "xml"},
+			expected: ErrUnknownFormat,
+		},
+		{
+			name:     "field without format",
+			flg:      Flags{Fields: []FieldKey{mustParseFieldKey("a")}},
+			expected: ErrIncompatibleFlags,
+		},
+		{
+			name:     "format with merge",
+			flg:      Flags{Format: FormatCSV, MergeOnly: true},
+			expected: ErrIncompatibleFlags,
+		},
+		{
+			name:     "jsonl with key",
+			flg:      Flags{Format: FormatJSONL, Keys: []KeySpec{mustParseKey("1")}},
+			expected: ErrIncompatibleFlags,
+		},
+		{
+			name:     "unknown field",
+			inputs:   []string{"a,b\n1,2\n"},
+			flg:      Flags{Format: FormatCSV, Fields: []FieldKey{mustParseFieldKey("c")}},
+			expected: ErrUnknownField,
+		},
+		{
+			name:     "header mismatch",
+			inputs:   []string{"a,b\n1,2\n", "b,a\n2,1\n"},
+			flg:      Flags{Format: FormatCSV},
+			expected: ErrHeaderMismatch,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorter, err := NewSorter(test.flg)
+			if err == nil {
+				inputs := make([]io.Reader, len(test.inputs))
+				for i, input := range test.inputs {
+					inputs[i] = strings.NewReader(input)
+				}
+				err = sorter.Sort(io.Discard, inputs...)
+			}
+
+			if !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+
+	sorter, err := NewSorter(Flags{Format: FormatJSONL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sorter.Sort(io.Discard, strings.NewReader("{}\n{")); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputs   []string
+		flg      Flags
+		expected string
+		warnings string
+	}{
+		{
+			name:     "default",
+			inputs:   []string{"apple\nbing\nQIWI", "Apple\nGoogle\n", "", "House\nYandex"},
+			expected: "apple\nApple\nbing\nGoogle\nHouse\nQIWI\nYandex\n",
+		},
+		{
+			name:     "numeric reverse",
+			inputs:   []string{"10\n3\n1", "9\n2"},
+			flg:      Flags{NumericSort: true, ReverseSort: true},
+			expected: "10\n9\n3\n2\n1\n",
+		},
+		{
+			name:     "unique",
+			inputs:   []string{"a 1\nc 1\nb 2", "a 1\nd 3"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2")}, UniqueValues: true},
+			expected: "a 1\nc 1\nb 2\nd 3\n",
+		},
+		{
+			name:     "unsorted input",
+			inputs:   []string{"a\nc\nb\na", "b\nd"},
+			expected: "a\nb\nc\nb\na\nd\n",
+			warnings: "sort: input 1:3: disorder: b\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorter, err := NewSorter(test.flg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var warnings strings.Builder
+			sorter.Warnings = &warnings
+
+			inputs := make([]io.Reader, len(test.inputs))
+			for i, input := range test.inputs {
+				inputs[i] = strings.NewReader(input)
+			}
+
+			var out strings.Builder
+			if err := sorter.Merge(&out, inputs...); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, out.String())
+			}
+			if warnings.String() != test.warnings {
+				t.Errorf("expected warnings %q, got %q", test.warnings, warnings.String())
+			}
+		})
+	}
+
+	t.Run("output is input", func(t *testing.T) {
+		dir := t.TempDir()
+		name := filepath.Join(dir, "data.txt")
+		if err := os.WriteFile(name, []byte("a\nc\n"), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		other := filepath.Join(dir, "other.txt")
+		if err := os.WriteFile(other, []byte("b\n"), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		sorter, err := NewSorter(Flags{MergeOnly: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := sorter.SortFiles(name, name, other); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if expected := "a\nb\nc\n"; string(data) != expected {
+			t.Errorf("expected %q, got %q", expected, data)
+		}
+	})
+}
+
+func TestCheckSorted(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		flg      Flags
+		disorder *DisorderError
+	}{
+		{
+			name:  "sorted",
+			input: []string{"apple", "Apple", "bing"},
+		},
+		{
+			name:     "disorder",
+			input:    []string{"a", "c", "b", "a"},
+			disorder: &DisorderError{Line: 3, Text: "b"},
+		},
+		{
+			name:  "reverse",
+			input: []string{"c", "b", "a"},
+			flg:   Flags{ReverseSort: true},
+		},
+		{
+			name:     "month key",
+			input:    []string{"x Jan", "y Mar", "z Feb"},
+			flg:      Flags{Keys: []KeySpec{mustParseKey("2,2M")}},
+			disorder: &DisorderError{Line: 3, Text: "z Feb"},
+		},
+		{
+			name:  "duplicates",
+			input: []string{"a", "b", "b"},
+		},
+		{
+			name:     "unique",
+			input:    []string{"a", "b", "b"},
+			flg:      Flags{UniqueValues: true},
+			disorder: &DisorderError{Line: 3, Text: "b"},
+		},
+		{
+			name:  "unique equal keys",
+			input: []string{"x 1", "y 1"},
+			flg:   Flags{Keys: []KeySpec{mustParseKey("2,2")}, UniqueValues: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sorter, err := NewSorter(test.flg)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err = sorter.Check(strings.NewReader(strings.Join(test.input, "\n")))
+
+			if test.disorder == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var disorder *DisorderError
+			if !errors.As(err, &disorder) {
+				t.Fatalf("expected disorder, got %v", err)
+			}
+			if *disorder != *test.disorder {
+				t.Errorf("expected %+v, got %+v", test.disorder, disorder)
+			}
+		})
+	}
+}
+
+// Разбирает ключ сортировки, при ошибке вызывает панику
+func mustParseKey(str string) KeySpec {
+	key, err := ParseKey(str)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected KeySpec
+		valid    bool
+	}{
+		{
+			input:    "2",
+			expected: KeySpec{StartField: 2, StartChar: 1},
+			valid:    true,
+		},
+		{
+			input:    "2,2nr",
+			expected: KeySpec{StartField: 2, StartChar: 1, EndField: 2, Options: KeyOptions{Numeric: true, Reverse: true}},
+			valid:    true,
+		},
+		{
+			input:    "2,2Mh",
+			expected: KeySpec{StartField: 2, StartChar: 1, EndField: 2, Options: KeyOptions{Month: true, Human: true}},
+			valid:    true,
+		},
+		{
+			input:    "1.3b,1.5b",
+			expected: KeySpec{StartField: 1, StartChar: 3, EndField: 1, EndChar: 5, Options: KeyOptions{StartBlanks: true, EndBlanks: true}},
+			valid:    true,
+		},
+		{input: "0", valid: false},
+		{input: "1.0", valid: false},
+		{input: "x", valid: false},
+		{input: "1,2q", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			key, err := ParseKey(test.input)
+			if test.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got error %v", test.valid, err)
+			}
+
+			if key != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, key)
+			}
+		})
+	}
+}
+
+func TestExtractKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		key       string
+		separator string
+		expected  string
+	}{
+		{name: "field with blanks", line: "a  bc d", key: "2,2", expected: "  bc"},
+		{name: "skip blanks", line: "a  bc d", key: "2b,2", expected: "bc"},
+		{name: "to end of line", line: "a  bc d", key: "2", expected: "  bc d"},
+		{name: "characters", line: "abc привет", key: "2.2,2.4", expected: "при"},
+		{name: "characters skip blanks", line: "abc привет", key: "2.2b,2.4b", expected: "рив"},
+		{name: "missing field", line: "a b", key: "3", expected: ""},
+		{name: "separator", line: "a::b:c", key: "3,3", separator: ":", expected: "b"},
+		{name: "empty field", line: "a::b:c", key: "2,2", separator: ":", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := extractKey(test.line, fieldSpans(test.line, test.separator), mustParseKey(test.key))
+
+			if result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		valid    bool
+	}{
+		{input: "ru_RU.UTF-8", expected: "ru-RU", valid: true},
+		{input: "ru-RU", expected: "ru-RU", valid: true},
+		{input: "de_DE@euro", expected: "de-DE", valid: true},
+		{input: "en", expected: "en", valid: true},
+		{input: "not a locale", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			tag, err := parseLocale(test.input)
+			if test.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got error %v", test.valid, err)
+			}
+
+			if test.valid && tag.String() != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, tag)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		valid    bool
+	}{
+		{input: "100", expected: 100, valid: true},
+		{input: "100b", expected: 100, valid: true},
+		{input: "512K", expected: 512 << 10, valid: true},
+		{input: "64M", expected: 64 << 20, valid: true},
+		{input: "2G", expected: 2 << 30, valid: true},
+		{input: "", valid: false},
+		{input: "M", valid: false},
+		{input: "-1K", valid: false},
+		{input: "10X", valid: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			size, err := parseSize(test.input)
+			if test.valid != (err == nil) {
+				t.Fatalf("expected valid %v, got error %v", test.valid, err)
+			}
+
+			if size != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, size)
+			}
+		})
+	}
+}
+
+/*
+Прежняя рекурсивная быстрая сортировка, оставлена для сравнения в бенчмарках
+start - индекс начала сортировки
+end - индекс конца сортировки
+less - функция сравнения строк
+*/
+func quickSort(strs []string, start, end int, less func(a, b string) bool) []string {
+	if start < end {
+		// Выбор опорного элемента
+		pivot := strs[start]
+		left := start
+		right := end
+
+		for left < right {
+			for left < right && !less(strs[right], pivot) {
+				right--
+			}
+
+			if left < right {
+				strs[left] = strs[right]
+				left++
+			}
+
+			for left < right && !less(pivot, strs[left]) {
+				left++
+			}
+
+			if left < right {
+				strs[right] = strs[left]
+				right--
+			}
+		}
+
+		// Помещение опорного элемента на правильное место
+		strs[left] = pivot
+
+		// Рекурсивное применение quickSort к двум частям массива
+		quickSort(strs, start, left-1, less)
+		quickSort(strs, left+1, end, less)
+	}
+
+	return strs
+}
+
+// Наборы данных для проверки сортировки
+func sortInputs(n int) map[string][]string {
+	rnd := rand.New(rand.NewSource(1))
+
+	random := make([]string, n)
+	sorted := make([]string, n)
+	reversed := make([]string, n)
+	duplicates := make([]string, n)
+	for i := 0; i < n; i++ {
+		random[i] = fmt.Sprintf("%08d", rnd.Intn(n))
+		sorted[i] = fmt.Sprintf("%08d", i)
+		reversed[i] = fmt.Sprintf("%08d", n-i)
+		duplicates[i] = fmt.Sprintf("%d", rnd.Intn(3))
+	}
+
+	return map[string][]string{
+		"random":     random,
+		"sorted":     sorted,
+		"reversed":   reversed,
+		"duplicates": duplicates,
+	}
+}
+
+func stringLess(a, b string) bool {
+	return a < b
+}
+
+func TestParallelSort(t *testing.T) {
+	for name, input := range sortInputs(10000) {
+		for _, parallel := range []int{1, 2, 3, 8} {
+			t.Run(fmt.Sprintf("%s/%d", name, parallel), func(t *testing.T) {
+				expected := append([]string(nil), input...)
+				sort.Strings(expected)
+
+				result := parallelSort(append([]string(nil), input...), parallel, false, stringLess)
+				if !linesEqual(expected, result) {
+					t.Error("wrong order")
+				}
+			})
+		}
+	}
+}
+
+func TestMergeSortStable(t *testing.T) {
+	input := sortInputs(1000)["duplicates"]
+	for i := range input {
+		input[i] = fmt.Sprintf("%s %04d", input[i], i)
+	}
+
+	// Сравнение только по первому символу: номера строк должны остаться по возрастанию
+	expected := append([]string(nil), input...)
+	sort.SliceStable(expected, func(i, j int) bool { return expected[i][0] < expected[j][0] })
+
+	for _, parallel := range []int{1, 3} {
+		result := parallelSort(append([]string(nil), input...), parallel, true, func(a, b string) bool {
+			return a[0] < b[0]
+		})
+		if !linesEqual(expected, result) {
+			t.Errorf("parallel %d: order of equal lines changed", parallel)
+		}
+	}
+}
+
+func TestHeapSort(t *testing.T) {
+	input := sortInputs(1000)["random"]
+	expected := append([]string(nil), input...)
+	sort.Strings(expected)
+
+	heapSort(input, stringLess)
+	if !linesEqual(expected, input) {
+		t.Error("wrong order")
+	}
+}
+
+func benchmarkSort(b *testing.B, sortFunc func([]string)) {
+	for name, input := range sortInputs(20000) {
+		b.Run(name, func(b *testing.B) {
+			data := make([]string, len(input))
+			for i := 0; i < b.N; i++ {
+				copy(data, input)
+				sortFunc(data)
+			}
+		})
+	}
+}
+
+func BenchmarkQuickSort(b *testing.B) {
+	benchmarkSort(b, func(data []string) {
+		quickSort(data, 0, len(data)-1, stringLess)
+	})
+}
+
+func BenchmarkIntroSort(b *testing.B) {
+	benchmarkSort(b, func(data []string) {
+		introSort(data, stringLess)
+	})
+}
+
+func BenchmarkParallelSort(b *testing.B) {
+	benchmarkSort(b, func(data []string) {
+		parallelSort(data, 4, false, stringLess)
+	})
+}
+
+func TestSplitShortFlags(t *testing.T) {
+	input := []string{"-k2,2nr", "-k", "1,1", "-t:", "-S=1M", "-parallel", "2", "--", "-kfile", "out"}
+	expected := []string{"-k", "2,2nr", "-k", "1,1", "-t", ":", "-S=1M", "-parallel", "2", "--", "-kfile", "out"}
+
+	if result := splitShortFlags(input); !linesEqual(expected, result) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+}
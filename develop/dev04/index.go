@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Число букв в гистограмме сигнатуры: a-z, а-я и ё
+const alphabetSize = 26 + 32 + 1
+
+// Номер буквы в гистограмме или -1 для остальных рун
+func letterIndex(r rune) int {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return int(r - 'a')
+	case r >= 'а' && r <= 'я':
+		return 26 + int(r-'а')
+	case r == 'ё':
+		return 26 + 32
+	}
+
+	return -1
+}
+
+/*
+Сигнатура слова, одинаковая у всех его анаграмм.
+Для латинских и русских букв это гистограмма: пары номер буквы и число её вхождений.
+Остальные руны, которые встречаются редко, сортируются и добавляются после байта 0xFF.
+В отличие от sortChars, слово не разбивается на строки и не сортируется целиком.
+*/
+func signature(word string) string {
+	var (
+		counts [alphabetSize]uint32
+		other  []rune
+	)
+
+	for _, r := range word {
+		if i := letterIndex(r); i >= 0 {
+			counts[i]++
+		} else {
+			other = append(other, r)
+		}
+	}
+
+	buf := make([]byte, 0, 2*len(word))
+	for i, count := range counts {
+		if count > 0 {
+			buf = append(buf, byte(i))
+			buf = binary.AppendUvarint(buf, uint64(count))
+		}
+	}
+
+	if len(other) > 0 {
+		slices.Sort(other)
+		buf = append(buf, 0xFF)
+		for _, r := range other {
+			buf = utf8.AppendRune(buf, r)
+		}
+	}
+
+	return string(buf)
+}
+
+// Множество анаграмм
+type anagramGroup struct {
+	first      string   // первое добавленное слово
+	normalized string   // нормализованная форма первого слова
+	words      []string // слова в порядке добавления, по одному на нормализованную форму
+	forms      []string // нормализованные формы слов words
+	applied    int      // шаги нормализации, изменившие хотя бы одно слово
+}
+
+/*
+Добавляет слово, если в множестве ещё нет слова с той же нормализованной формой.
+Разные написания одного слова, например NFC и NFD, - повтор, а не анаграмма.
+*/
+func (g *anagramGroup) add(word, normalized string, applied int) {
+	// Множества анаграмм малы, поэтому линейный поиск дешевле отдельной карты
+	if !slices.Contains(g.forms, normalized) {
+		g.words = append(g.words, word)
+		g.forms = append(g.forms, normalized)
+		g.applied |= applied
+	}
+}
+
+// Слова множества по возрастанию
+func (g *anagramGroup) sorted() []string {
+	words := slices.Clone(g.words)
+	slices.Sort(words)
+
+	return words
+}
+
+/*
+Индекс анаграмм, пополняемый по одному слову.
+Слова приводятся к нижнему регистру, нормализуются и группируются по сигнатуре,
+поэтому словарь можно читать из потока за один проход без сортировки.
+В множествах хранятся слова в нижнем регистре без нормализации.
+*/
+type AnagramIndex struct {
+	groups map[string]*anagramGroup
+	opt    NormalizeOptions
+}
+
+func NewAnagramIndex() *AnagramIndex {
+	return NewAnagramIndexWithOptions(NormalizeOptions{})
+}
+
+// Создаёт индекс, который перед группировкой нормализует слова по opt
+func NewAnagramIndexWithOptions(opt NormalizeOptions) *AnagramIndex {
+	return &AnagramIndex{groups: make(map[string]*anagramGroup), opt: opt}
+}
+
+// Параметры нормализации индекса
+func (idx *AnagramIndex) Options() NormalizeOptions {
+	return idx.opt
+}
+
+// Добавляет слово в индекс, пустые после нормализации слова и повторы нормализованных форм игнорируются
+func (idx *AnagramIndex) Add(word string) {
+	word = strings.ToLower(word)
+
+	normalized, applied := idx.opt.normalize(word)
+	if normalized == "" {
+		return
+	}
+
+	sign := signature(normalized)
+
+	group, ok := idx.groups[sign]
+	if !ok {
+		group = &anagramGroup{first: word, normalized: normalized}
+		idx.groups[sign] = group
+	}
+	group.add(word, normalized, applied)
+}
+
+/*
+Добавляет в индекс слова из r, разделённые пробельными символами.
+Возвращает число прочитанных слов.
+*/
+func (idx *AnagramIndex) ReadWords(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+
+	n := 0
+	for scanner.Scan() {
+		idx.Add(scanner.Text())
+		n++
+	}
+
+	return n, scanner.Err()
+}
+
+// Возвращает отсортированные слова индекса, являющиеся анаграммами word, включая само слово
+func (idx *AnagramIndex) Lookup(word string) []string {
+	normalized, _ := idx.opt.normalize(strings.ToLower(word))
+
+	group, ok := idx.groups[signature(normalized)]
+	if !ok {
+		return nil
+	}
+
+	return group.sorted()
+}
+
+/*
+Возвращает множества анаграмм из двух и более слов.
+Ключ - первое добавленное слово множества,
+значение - все слова множества по возрастанию.
+*/
+func (idx *AnagramIndex) Groups() map[string][]string {
+	result := make(map[string][]string)
+
+	for _, group := range idx.groups {
+		if len(group.words) > 1 {
+			result[group.first] = group.sorted()
+		}
+	}
+
+	return result
+}
+
+// Множество анаграмм с описанием применённой нормализации
+type GroupReport struct {
+	Key        string   `json:"key"`        // первое добавленное слово
+	Words      []string `json:"words"`      // слова множества по возрастанию
+	Normalized string   `json:"normalized"` // нормализованная форма ключа, по которой построена сигнатура
+	Applied    []string `json:"applied"`    // шаги нормализации, изменившие хотя бы одно слово множества
+}
+
+// Возвращает множества анаграмм из двух и более слов по возрастанию ключа
+func (idx *AnagramIndex) Report() []GroupReport {
+	var reports []GroupReport
+
+	for _, group := range idx.groups {
+		if len(group.words) > 1 {
+			reports = append(reports, GroupReport{
+				Key:        group.first,
+				Words:      group.sorted(),
+				Normalized: group.normalized,
+				Applied:    idx.opt.stepNames(group.applied),
+			})
+		}
+	}
+
+	slices.SortFunc(reports, func(a, b GroupReport) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return reports
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Форма нормализации Unicode
+type Form int
+
+const (
+	// Без нормализации: разные записи одной буквы дают разные сигнатуры
+	FormNone Form = iota
+	// Каноническая композиция: е + U+0308 => ё
+	FormNFC
+	// Совместимая декомпозиция: ё => е + U+0308, ﬁ => fi
+	FormNFKD
+)
+
+// Ошибка разбора названия формы нормализации
+var ErrUnknownForm = errors.New("unknown normalization form")
+
+// Разбирает название формы нормализации: none, nfc или nfkd
+func ParseForm(str string) (Form, error) {
+	switch strings.ToLower(str) {
+	case "", "none":
+		return FormNone, nil
+	case "nfc":
+		return FormNFC, nil
+	case "nfkd":
+		return FormNFKD, nil
+	}
+
+	return FormNone, fmt.Errorf("%w: %q", ErrUnknownForm, str)
+}
+
+// Шаги нормализации в порядке применения
+const (
+	stepYo = 1 << iota
+	stepDiacritics
+	stepLetters
+	stepForm
+)
+
+/*
+Параметры нормализации слов перед группировкой.
+Нулевое значение соответствует прежнему поведению: только нижний регистр.
+*/
+type NormalizeOptions struct {
+	Form            Form // форма нормализации Unicode
+	StripDiacritics bool // удалять диакритические знаки: é => e, й => и
+	FoldYo          bool // считать ё буквой е
+	LettersOnly     bool // пропускать всё, кроме букв: дефисы, пунктуацию, цифры
+}
+
+// Имена шагов нормализации для отчёта
+func (opt NormalizeOptions) stepNames(steps int) []string {
+	var names []string
+
+	if steps&stepYo != 0 {
+		names = append(names, "yo")
+	}
+	if steps&stepDiacritics != 0 {
+		names = append(names, "strip-diacritics")
+	}
+	if steps&stepLetters != 0 {
+		names = append(names, "letters-only")
+	}
+	if steps&stepForm != 0 {
+		switch opt.Form {
+		case FormNFC:
+			names = append(names, "nfc")
+		case FormNFKD:
+			names = append(names, "nfkd")
+		}
+	}
+
+	return names
+}
+
+// Все включённые шаги нормализации
+func (opt NormalizeOptions) steps() int {
+	steps := 0
+
+	if opt.FoldYo {
+		steps |= stepYo
+	}
+	if opt.StripDiacritics {
+		steps |= stepDiacritics
+	}
+	if opt.LettersOnly {
+		steps |= stepLetters
+	}
+	if opt.Form != FormNone {
+		steps |= stepForm
+	}
+
+	return steps
+}
+
+// Описание включённой нормализации, например "lower+yo+nfc"
+func (opt NormalizeOptions) String() string {
+	return strings.Join(append([]string{"lower"}, opt.stepNames(opt.steps())...), "+")
+}
+
+// Замена ё на е в составной и разложенной записи
+var yoReplacer = strings.NewReplacer("ё", "е", "е\u0308", "е")
+
+/*
+Приводит слово в нижнем регистре к нормализованной форме.
+Возвращает форму и шаги, которые изменили слово.
+*/
+func (opt NormalizeOptions) normalize(word string) (string, int) {
+	applied := 0
+
+	apply := func(step int, f func(string) string) {
+		if normalized := f(word); normalized != word {
+			word = normalized
+			applied |= step
+		}
+	}
+
+	if opt.FoldYo {
+		apply(stepYo, yoReplacer.Replace)
+	}
+	if opt.StripDiacritics {
+		apply(stepDiacritics, stripDiacritics)
+	}
+	if opt.LettersOnly {
+		apply(stepLetters, lettersOnly)
+	}
+
+	switch opt.Form {
+	case FormNFC:
+		apply(stepForm, norm.NFC.String)
+	case FormNFKD:
+		apply(stepForm, norm.NFKD.String)
+	}
+
+	return word, applied
+}
+
+// Удаляет диакритические знаки, раскладывая буквы на основу и знаки
+func stripDiacritics(word string) string {
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, norm.NFD.String(word))
+
+	return norm.NFC.String(stripped)
+}
+
+// Оставляет только буквы и относящиеся к ним диакритические знаки
+func lettersOnly(word string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.Is(unicode.Mn, r) {
+			return r
+		}
+		return -1
+	}, word)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Ограничения по умолчанию для поиска по набору букв
+const (
+	DefaultMaxResults = 100
+	DefaultMaxWords   = 4
+	DefaultMaxSteps   = 1000000
+)
+
+// Ошибка превышения ограничений поиска, найденные к этому моменту результаты возвращаются
+var ErrSearchLimit = errors.New("search limit exceeded")
+
+// Ограничения перебора, нулевые значения заменяются значениями по умолчанию
+type SearchLimits struct {
+	MaxResults int // максимальное число результатов
+	MaxWords   int // максимальное число слов во фразе
+	MaxSteps   int // максимальное число шагов перебора
+}
+
+func (l SearchLimits) withDefaults() SearchLimits {
+	if l.MaxResults <= 0 {
+		l.MaxResults = DefaultMaxResults
+	}
+	if l.MaxWords <= 0 {
+		l.MaxWords = DefaultMaxWords
+	}
+	if l.MaxSteps <= 0 {
+		l.MaxSteps = DefaultMaxSteps
+	}
+
+	return l
+}
+
+/*
+Набор букв: различные руны и число вхождений каждой.
+Слова раскладываются по рунам набора, из которого их ищут.
+*/
+type letterSet struct {
+	runes  []rune
+	counts []int
+}
+
+// Составляет набор букв строки в нижнем регистре, пробельные символы пропускаются
+func newLetterSet(str string) letterSet {
+	var set letterSet
+
+	for _, r := range strings.ToLower(str) {
+		if unicode.IsSpace(r) {
+			continue
+		}
+
+		if i := slices.Index(set.runes, r); i >= 0 {
+			set.counts[i]++
+			continue
+		}
+		set.runes = append(set.runes, r)
+		set.counts = append(set.counts, 1)
+	}
+
+	return set
+}
+
+// Составляет набор букв строки после нормализации индекса, слова нормализуются по отдельности
+func (idx *AnagramIndex) letterSet(str string) letterSet {
+	words := strings.Fields(strings.ToLower(str))
+	for i, word := range words {
+		words[i], _ = idx.opt.normalize(word)
+	}
+
+	return newLetterSet(strings.Join(words, " "))
+}
+
+// Раскладывает слово по рунам набора, false - слово нельзя составить из набора
+func (set letterSet) vector(word string) ([]int, bool) {
+	vector := make([]int, len(set.runes))
+
+	for _, r := range word {
+		i := slices.Index(set.runes, r)
+		if i < 0 {
+			return nil, false
+		}
+
+		vector[i]++
+		if vector[i] > set.counts[i] {
+			return nil, false
+		}
+	}
+
+	return vector, true
+}
+
+// Множество анаграмм, которое можно составить из набора букв
+type candidate struct {
+	words  []string
+	vector []int
+	size   int // число букв
+}
+
+// Множества анаграмм индекса, слова которых составляются из набора букв
+func (idx *AnagramIndex) candidates(set letterSet) []candidate {
+	var result []candidate
+
+	for _, group := range idx.groups {
+		if vector, ok := set.vector(group.normalized); ok {
+			result = append(result, candidate{
+				words:  group.sorted(),
+				vector: vector,
+				size:   utf8.RuneCountInString(group.normalized),
+			})
+		}
+	}
+
+	// Порядок карты случаен, сортируем для воспроизводимого перебора
+	slices.SortFunc(result, func(a, b candidate) int {
+		return strings.Compare(a.words[0], b.words[0])
+	})
+
+	return result
+}
+
+/*
+Возвращает слова индекса, которые можно составить из букв letters,
+каждая буква используется не больше, чем встречается в letters.
+Слова упорядочены по убыванию длины, затем по возрастанию.
+Если слов больше limits.MaxResults, возвращаются первые из них и ErrSearchLimit.
+*/
+func (idx *AnagramIndex) SubAnagrams(letters string, limits SearchLimits) ([]string, error) {
+	limits = limits.withDefaults()
+
+	var words []string
+	for _, c := range idx.candidates(idx.letterSet(letters)) {
+		words = append(words, c.words...)
+	}
+
+	slices.SortFunc(words, func(a, b string) int {
+		if la, lb := utf8.RuneCountInString(a), utf8.RuneCountInString(b); la != lb {
+			return lb - la
+		}
+		return strings.Compare(a, b)
+	})
+
+	if len(words) > limits.MaxResults {
+		return words[:limits.MaxResults], ErrSearchLimit
+	}
+
+	return words, nil
+}
+
+// Состояние перебора фраз
+type phraseSearch struct {
+	candidates []candidate
+	remaining  []int
+	left       int   // число неиспользованных букв
+	chosen     []int // номера выбранных множеств
+	limits     SearchLimits
+	steps      int
+	results    [][]string
+	seen       map[string]bool
+}
+
+/*
+Возвращает фразы из слов индекса, являющиеся анаграммами phrase:
+вместе слова фразы используют все буквы phrase, кроме пробелов, ровно по одному разу.
+Слова во фразе и сами фразы упорядочены по возрастанию.
+Перебор ограничен limits: при превышении числа шагов или результатов
+возвращаются найденные фразы и ErrSearchLimit.
+*/
+func (idx *AnagramIndex) PhraseAnagrams(phrase string, limits SearchLimits) ([][]string, error) {
+	set := idx.letterSet(phrase)
+
+	search := &phraseSearch{
+		candidates: idx.candidates(set),
+		remaining:  slices.Clone(set.counts),
+		limits:     limits.withDefaults(),
+		seen:       make(map[string]bool),
+	}
+	for _, count := range set.counts {
+		search.left += count
+	}
+
+	var err error
+	if search.left > 0 {
+		err = search.run(0)
+	}
+
+	slices.SortFunc(search.results, func(a, b []string) int {
+		return slices.Compare(a, b)
+	})
+
+	return search.results, err
+}
+
+// Перебирает множества начиная с номера start, чтобы не повторять их перестановки
+func (s *phraseSearch) run(start int) error {
+	s.steps++
+	if s.steps > s.limits.MaxSteps {
+		return ErrSearchLimit
+	}
+
+	if s.left == 0 {
+		return s.collect()
+	}
+	if len(s.chosen) == s.limits.MaxWords {
+		return nil
+	}
+
+	for i := start; i < len(s.candidates); i++ {
+		c := s.candidates[i]
+		if !s.take(c.vector) {
+			continue
+		}
+		s.left -= c.size
+		s.chosen = append(s.chosen, i)
+
+		err := s.run(i)
+
+		s.chosen = s.chosen[:len(s.chosen)-1]
+		s.left += c.size
+		s.put(c.vector)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Вычитает буквы из оставшихся, false - букв не хватает
+func (s *phraseSearch) take(vector []int) bool {
+	for i, count := range vector {
+		if count > s.remaining[i] {
+			return false
+		}
+	}
+	for i, count := range vector {
+		s.remaining[i] -= count
+	}
+
+	return true
+}
+
+// Возвращает буквы в оставшиеся
+func (s *phraseSearch) put(vector []int) {
+	for i, count := range vector {
+		s.remaining[i] += count
+	}
+}
+
+// Добавляет в результат все фразы из слов выбранных множеств
+func (s *phraseSearch) collect() error {
+	words := make([]string, len(s.chosen))
+
+	var expand func(pos int) error
+	expand = func(pos int) error {
+		if pos == len(s.chosen) {
+			phrase := slices.Clone(words)
+			slices.Sort(phrase)
+
+			// Одно множество, выбранное дважды, даёт одинаковые фразы в разном порядке
+			key := strings.Join(phrase, " ")
+			if s.seen[key] {
+				return nil
+			}
+			if len(s.results) == s.limits.MaxResults {
+				return ErrSearchLimit
+			}
+
+			s.seen[key] = true
+			s.results = append(s.results, phrase)
+			return nil
+		}
+
+		for _, word := range s.candidates[s.chosen[pos]].words {
+			words[pos] = word
+			if err := expand(pos + 1); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return expand(0)
+}
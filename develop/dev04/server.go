@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMissingWord      = errors.New("word is required")
+	ErrInvalidMinSize   = errors.New("min_size must be a positive number")
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+const (
+	contentType     = "Content-Type"
+	applicationJson = "application/json"
+)
+
+// Ответ с ошибкой
+type errorResponse struct {
+	Message string `json:"error"`
+}
+
+// Ответ с результатом
+type resultResponse struct {
+	Message interface{} `json:"result"`
+}
+
+// Пишет ответ с ошибкой
+func writeJsonErrorResponse(w http.ResponseWriter, statusCode int, err error) {
+	response := errorResponse{
+		Message: err.Error(),
+	}
+	w.Header().Set(contentType, applicationJson)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Пишет ответ с результатом
+func writeJsonResultResponse(w http.ResponseWriter, statusCode int, result interface{}) {
+	response := resultResponse{
+		Message: result,
+	}
+	w.Header().Set(contentType, applicationJson)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(response)
+}
+
+// Анаграммы слова
+type anagramsResult struct {
+	Word          string   `json:"word"`
+	Anagrams      []string `json:"anagrams"`
+	Normalization string   `json:"normalization"`
+}
+
+// Структура хэндлера
+type Handler struct {
+	store *IndexStore
+}
+
+// Конструктор хэндлера
+func NewHandler(store *IndexStore) *Handler {
+	return &Handler{
+		store: store,
+	}
+}
+
+// Инициализация хэндлеров
+func (h Handler) InitRoutes() http.Handler {
+	router := http.NewServeMux()
+
+	router.HandleFunc("/anagrams", h.Anagrams)
+	router.HandleFunc("/groups", h.Groups)
+
+	return logRequests(router)
+}
+
+// Middleware для логирования запросов
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("method: %s, path: %s, query: %s", r.Method, r.URL.Path, r.URL.RawQuery)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Хэндлер поиска анаграмм слова: GET /anagrams?word=...
+func (h Handler) Anagrams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJsonErrorResponse(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	word := strings.TrimSpace(r.URL.Query().Get("word"))
+	if word == "" {
+		writeJsonErrorResponse(w, http.StatusBadRequest, ErrMissingWord)
+		return
+	}
+
+	anagrams := h.store.Lookup(word)
+	if anagrams == nil {
+		anagrams = []string{}
+	}
+
+	writeJsonResultResponse(w, http.StatusOK, anagramsResult{
+		Word:          word,
+		Anagrams:      anagrams,
+		Normalization: h.store.Options().String(),
+	})
+}
+
+/*
+Хэндлер множеств анаграмм: GET /groups?min_size=N.
+Множества из одного слова не хранятся, поэтому по умолчанию и при N = 1 выводятся все множества.
+*/
+func (h Handler) Groups(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJsonErrorResponse(w, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+		return
+	}
+
+	minSize := 2
+	if str := r.URL.Query().Get("min_size"); str != "" {
+		size, err := strconv.Atoi(str)
+		if err != nil || size < 1 {
+			writeJsonErrorResponse(w, http.StatusBadRequest, ErrInvalidMinSize)
+			return
+		}
+		minSize = size
+	}
+
+	writeJsonResultResponse(w, http.StatusOK, h.store.Groups(minSize))
+}
+
+// Структура сервера
+type Server struct {
+	httpServer http.Server
+}
+
+// Конструктор сервера
+func NewServer(addr string, handler http.Handler) *Server {
+	return &Server{
+		httpServer: http.Server{
+			Addr:    addr,
+			Handler: handler,
+		},
+	}
+}
+
+// Запуск сервера
+func (srv *Server) Start() error {
+	return srv.httpServer.ListenAndServe()
+}
+
+// Закрытие сервера
+func (srv *Server) Shutdown(ctx context.Context) error {
+	return srv.httpServer.Shutdown(ctx)
+}
+
+/*
+Читает слова из r, по одному или несколько в строке,
+и для каждого выводит в w найденные анаграммы через пробел.
+*/
+func runCLI(store *IndexStore, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+
+	out := bufio.NewWriter(w)
+	for scanner.Scan() {
+		word := scanner.Text()
+		if _, err := fmt.Fprintf(out, "%s: %s\n", word, strings.Join(store.Lookup(word), " ")); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Индекс словаря и построенные по нему множества анаграмм
+type snapshot struct {
+	index  *AnagramIndex
+	groups []GroupReport
+}
+
+/*
+Хранилище индекса анаграмм словарного файла.
+Множества строятся один раз при загрузке. При перезагрузке новый индекс
+строится отдельно и подменяется целиком, поэтому запросы, начатые раньше,
+дорабатывают со старым индексом, а ошибка загрузки оставляет прежний.
+*/
+type IndexStore struct {
+	path    string
+	opt     NormalizeOptions
+	current atomic.Pointer[snapshot]
+	mutex   sync.Mutex // не даёт перезагрузкам выполняться одновременно
+}
+
+// Загружает словарь из файла path с нормализацией opt
+func LoadIndexStore(path string, opt NormalizeOptions) (*IndexStore, error) {
+	store := &IndexStore{path: path, opt: opt}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Перечитывает словарь из файла
+func (s *IndexStore) Reload() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	index := NewAnagramIndexWithOptions(s.opt)
+	if _, err := index.ReadWords(file); err != nil {
+		return err
+	}
+
+	s.current.Store(&snapshot{index: index, groups: index.Report()})
+
+	return nil
+}
+
+// Возвращает анаграммы слова из словаря
+func (s *IndexStore) Lookup(word string) []string {
+	return s.current.Load().index.Lookup(word)
+}
+
+// Возвращает множества анаграмм не меньше чем из minSize слов
+func (s *IndexStore) Groups(minSize int) []GroupReport {
+	groups := s.current.Load().groups
+
+	result := make([]GroupReport, 0, len(groups))
+	for _, group := range groups {
+		if len(group.Words) >= minSize {
+			result = append(result, group)
+		}
+	}
+
+	return result
+}
+
+// Параметры нормализации словаря
+func (s *IndexStore) Options() NormalizeOptions {
+	return s.opt
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
-	"sort"
-	"strings"
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 )
 
 /*
@@ -20,90 +27,116 @@ import (
 	Программа должна проходить все тесты. Код должен проходить проверки go vet и golint.
 */
 
-// Сортировка массива строк с использование алгоритма быстрой сортировки
-func quickSort(words []string, start, end int) *[]string {
-	if start < end {
-		// Выбор опорного элемента
-		pivot := words[start]
-		left := start + 1
-		right := end
-
-		for left <= right {
-			for left <= right && words[left] <= pivot {
-				left++
-			}
-
-			for left <= right && words[right] >= pivot {
-				right--
-			}
-
-			if left < right {
-				words[left], words[right] = words[right], words[left]
-			}
-		}
-
-		words[start], words[right] = words[right], words[start]
+/*
+Поиск анаграмм.
+Ключ - первое встретившееся в словаре слово множества в нижнем регистре,
+значение - остальные слова множества без повторов по возрастанию.
+Как и в исходной реализации, сам ключ в значение не входит.
+Множества из одного слова в результат не попадают.
+*/
+func FindAnagrams(dict *[]string) *map[string][]string {
+	idx := NewAnagramIndex()
+	for _, word := range *dict {
+		idx.Add(word)
+	}
 
-		// Рекурсивное применение quickSort к двум частям массива
-		words = *quickSort(words, start, right-1)
-		words = *quickSort(words, right+1, end)
+	result := idx.Groups()
+	for key, words := range result {
+		result[key] = slices.DeleteFunc(words, func(word string) bool {
+			return word == key
+		})
 	}
 
-	return &words
+	return &result
 }
 
-// Приводит строки к нижнему регистру
-func toLower(words *[]string) *[]string {
-	result := make([]string, len(*words))
+// Флаги командной строки
+type Flags struct {
+	Dict      string           // -dict файл словаря
+	Addr      string           // -addr адрес HTTP-сервера
+	CLI       bool             // -cli читать слова со стандартного ввода вместо запуска сервера
+	Normalize NormalizeOptions // -form, -strip-diacritics, -yo, -letters-only
+}
 
-	for i, str := range *words {
-		result[i] = strings.ToLower(str)
+// Парсит аргументы командной строки
+func parseFlags() Flags {
+	dict := flag.String("dict", "", "dictionary file, words separated by whitespace")
+	addr := flag.String("addr", ":8080", "HTTP server address")
+	cli := flag.Bool("cli", false, "read words from stdin and print their anagrams")
+	form := flag.String("form", "none", "unicode normalization form: none, nfc or nfkd")
+	stripDiacritics := flag.Bool("strip-diacritics", false, "strip diacritical marks")
+	foldYo := flag.Bool("yo", false, "treat ё as е")
+	lettersOnly := flag.Bool("letters-only", false, "ignore everything but letters")
+	flag.Parse()
+
+	if *dict == "" {
+		log.Fatal("usage: go run . -dict dictionary-file [-cli]")
 	}
 
-	return &result
-}
-
-// Сортирует символы в строке
-func sortChars(word string) string {
-	chars := strings.Split(word, "")
-	sort.Strings(chars)
+	normForm, err := ParseForm(*form)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	return strings.Join(chars, "")
+	return Flags{
+		Dict: *dict,
+		Addr: *addr,
+		CLI:  *cli,
+		Normalize: NormalizeOptions{
+			Form:            normForm,
+			StripDiacritics: *stripDiacritics,
+			FoldYo:          *foldYo,
+			LettersOnly:     *lettersOnly,
+		},
+	}
 }
 
-// Поиск анаграмм
-func FindAnagrams(dict *[]string) *map[string][]string {
-	// Приводим массив строк к нижнему регистру
-	dict = toLower(dict)
+func main() {
+	flg := parseFlags()
 
-	// Применяем быструю сортировку к массиву строк
-	dict = quickSort(*dict, 0, len(*dict)-1)
+	// Загружаем словарь и строим множества анаграмм
+	store, err := LoadIndexStore(flg.Dict, flg.Normalize)
+	if err != nil {
+		log.Fatalf("failed to load dictionary: %v", err)
+	}
 
-	// Создаем временную карты для хранения анаграмм
-	tempMap := make(map[string][]string)
-	nameMap := make(map[string]string)
+	if flg.CLI {
+		if err := runCLI(store, os.Stdin, os.Stdout); err != nil {
+			log.Fatalf("failed to read words: %v", err)
+		}
+		return
+	}
 
-	// Поиск анаграмм в массиве строк
-	for _, word := range *dict {
-		if len(word) > 1 {
-			sortedWord := sortChars(word)
-			if _, ok := tempMap[sortedWord]; ok {
-				tempMap[sortedWord] = append(tempMap[sortedWord], word)
+	server := NewServer(flg.Addr, NewHandler(store).InitRoutes())
+
+	go func() {
+		// Запускаем сервер
+		if err := server.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("failed to start server: %v", err)
+		}
+	}()
+
+	// Перезагрузка словаря по SIGHUP, запросы продолжают обслуживаться прежним индексом
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := store.Reload(); err != nil {
+				log.Printf("failed to reload dictionary: %v", err)
 				continue
 			}
-			tempMap[sortedWord] = make([]string, 0, 1)
-			nameMap[sortedWord] = word
+			log.Print("dictionary reloaded")
 		}
-	}
+	}()
 
-	// Формируем результирующую карту анаграмм
-	resultMap := make(map[string][]string)
-	for sortedWord, word := range tempMap {
-		if len(word) != 0 {
-			resultMap[nameMap[sortedWord]] = word
-		}
+	// Graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	<-quit
 
-	}
+	log.Print("shutting down")
 
-	return &resultMap
+	if err := server.Shutdown(context.Background()); err != nil {
+		log.Fatalf("failed to shutdown server: %v", err)
+	}
 }
@@ -1,6 +1,20 @@
 package main
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/quick"
+)
 
 func Test_FindAnagrams(t *testing.T) {
 	input := []string{
@@ -19,6 +33,168 @@ func Test_FindAnagrams(t *testing.T) {
 	}
 }
 
+func Test_FindAnagramsContract(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []string
+		expected map[string][]string
+	}{
+		{
+			name:     "first occurrence key",
+			input:    []string{"тяпка", "пятак", "пятка"},
+			expected: map[string][]string{"тяпка": {"пятак", "пятка"}},
+		},
+		{
+			name:     "duplicates",
+			input:    []string{"кот", "ток", "кот", "Ток", "КОТ"},
+			expected: map[string][]string{"кот": {"ток"}},
+		},
+		{
+			name:     "only duplicates",
+			input:    []string{"кот", "Кот", "слон"},
+			expected: map[string][]string{},
+		},
+		{
+			name:     "key in upper case",
+			input:    []string{"Листок", "слиток"},
+			expected: map[string][]string{"листок": {"слиток"}},
+		},
+		{
+			name:     "empty",
+			input:    []string{},
+			expected: map[string][]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if result := *FindAnagrams(&test.input); !mapEquals(result, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+// Словарь из случайных русских и латинских слов для проверки свойств
+type randomDict []string
+
+func (randomDict) Generate(rnd *rand.Rand, size int) reflect.Value {
+	alphabets := [][]rune{
+		[]rune("абвгдеёжзийклмнопрстуфхцчшщъыьэюяАБВГДЕЁЖЗ"),
+		[]rune("abcdefghijklmnopqrstuvwxyzABCDEFG"),
+	}
+
+	dict := make(randomDict, 0, size)
+	for len(dict) < size {
+		letters := alphabets[rnd.Intn(len(alphabets))]
+
+		// Короткие слова из малого набора букв чаще оказываются анаграммами
+		word := make([]rune, 1+rnd.Intn(4))
+		for i := range word {
+			word[i] = letters[rnd.Intn(6+rnd.Intn(len(letters)-6))]
+		}
+		dict = append(dict, string(word))
+
+		// Перестановки и повторы уже добавленных слов
+		for rnd.Intn(2) == 0 && len(dict) < size {
+			word := []rune(dict[rnd.Intn(len(dict))])
+			rnd.Shuffle(len(word), func(a, b int) { word[a], word[b] = word[b], word[a] })
+			dict = append(dict, string(word))
+		}
+	}
+
+	return reflect.ValueOf(dict)
+}
+
+// Независимая от signature проверка анаграмм: отсортированные руны в нижнем регистре
+func sortedRunes(word string) string {
+	runes := []rune(strings.ToLower(word))
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	return string(runes)
+}
+
+// Проверяет инварианты результата FindAnagrams для словаря dict
+func checkAnagramGroups(dict []string, groups map[string][]string) error {
+	// Номер первого вхождения каждого слова в нижнем регистре
+	first := make(map[string]int)
+	partners := make(map[string]map[string]bool)
+	for i, word := range dict {
+		word = strings.ToLower(word)
+		if _, ok := first[word]; !ok {
+			first[word] = i
+		}
+
+		sign := sortedRunes(word)
+		if partners[sign] == nil {
+			partners[sign] = make(map[string]bool)
+		}
+		partners[sign][word] = true
+	}
+
+	seen := make(map[string]string)
+	for key, words := range groups {
+		if len(words) == 0 {
+			return fmt.Errorf("group %q has no words besides the key", key)
+		}
+		if !sort.StringsAreSorted(words) {
+			return fmt.Errorf("group %q is not sorted: %v", key, words)
+		}
+
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("key %q is also in group %q", key, other)
+		}
+		seen[key] = key
+
+		for i, word := range words {
+			if i > 0 && words[i-1] == word {
+				return fmt.Errorf("group %q has duplicate %q", key, word)
+			}
+			if word != strings.ToLower(word) {
+				return fmt.Errorf("group %q has word %q not in lower case", key, word)
+			}
+			if word == key {
+				return fmt.Errorf("group %q contains its key", key)
+			}
+			if sortedRunes(word) != sortedRunes(key) {
+				return fmt.Errorf("group %q has word %q that is not an anagram", key, word)
+			}
+			if other, ok := seen[word]; ok {
+				return fmt.Errorf("word %q is in groups %q and %q", word, other, key)
+			}
+			if first[word] < first[key] {
+				return fmt.Errorf("group %q: word %q occurs earlier than the key", key, word)
+			}
+			seen[word] = key
+		}
+	}
+
+	// Каждое слово, у которого есть другая анаграмма в словаре, попадает в результат
+	for word := range first {
+		if _, ok := seen[word]; !ok && len(partners[sortedRunes(word)]) > 1 {
+			return fmt.Errorf("word %q with anagrams is missing", word)
+		}
+	}
+
+	return nil
+}
+
+func Test_FindAnagramsProperties(t *testing.T) {
+	property := func(dict randomDict) bool {
+		input := []string(dict)
+		if err := checkAnagramGroups(input, *FindAnagrams(&input)); err != nil {
+			t.Log(err)
+			return false
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 300}); err != nil {
+		t.Error(err)
+	}
+}
+
 func mapEquals(currentAnagrams map[string][]string, expectedAnagrams map[string][]string) bool {
 	if len(currentAnagrams) != len(expectedAnagrams) {
 		return false
@@ -46,3 +222,625 @@ func sliceEquals(expectedAnagramsKeys []string, values []string) bool {
 
 	return true
 }
+
+func TestAnagramIndex(t *testing.T) {
+	idx := NewAnagramIndex()
+
+	n, err := idx.ReadWords(strings.NewReader("пятак листок Пятка\nслиток тяпка  столик\tкот ток отк токио пятак"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 11 {
+		t.Errorf("expected 11 words, got %d", n)
+	}
+	idx.Add("")
+
+	expectedGroups := map[string][]string{
+		"пятак":  {"пятак", "пятка", "тяпка"},
+		"листок": {"листок", "слиток", "столик"},
+		"кот":    {"кот", "отк", "ток"},
+	}
+	if groups := idx.Groups(); !mapEquals(groups, expectedGroups) {
+		t.Errorf("expected %v, got %v", expectedGroups, groups)
+	}
+
+	tests := []struct {
+		word     string
+		expected []string
+	}{
+		{word: "ТЯПКА", expected: []string{"пятак", "пятка", "тяпка"}},
+		{word: "окт", expected: []string{"кот", "отк", "ток"}},
+		{word: "токио", expected: []string{"токио"}},
+		{word: "слон", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.word, func(t *testing.T) {
+			if result := idx.Lookup(test.word); !sliceEquals(test.expected, result) {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		opt      NormalizeOptions
+		word     string
+		expected string
+		applied  []string
+	}{
+		{name: "none", word: "ёлка-1", expected: "ёлка-1"},
+		{name: "yo", opt: NormalizeOptions{FoldYo: true}, word: "ёлка", expected: "елка", applied: []string{"yo"}},
+		{name: "yo decomposed", opt: NormalizeOptions{FoldYo: true}, word: "е\u0308лка", expected: "елка", applied: []string{"yo"}},
+		{name: "nfc", opt: NormalizeOptions{Form: FormNFC}, word: "е\u0308ж", expected: "ёж", applied: []string{"nfc"}},
+		{name: "nfc unchanged", opt: NormalizeOptions{Form: FormNFC}, word: "ёж", expected: "ёж"},
+		{name: "nfkd", opt: NormalizeOptions{Form: FormNFKD}, word: "ﬁё", expected: "fiе\u0308", applied: []string{"nfkd"}},
+		{name: "diacritics", opt: NormalizeOptions{StripDiacritics: true}, word: "café-йод", expected: "cafe-иод", applied: []string{"strip-diacritics"}},
+		{name: "letters only", opt: NormalizeOptions{LettersOnly: true}, word: "кто-то, 2", expected: "ктото", applied: []string{"letters-only"}},
+		{
+			name:     "all",
+			opt:      NormalizeOptions{Form: FormNFC, StripDiacritics: true, FoldYo: true, LettersOnly: true},
+			word:     "ёж-é",
+			expected: "еж" + "e",
+			applied:  []string{"yo", "strip-diacritics", "letters-only"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, applied := test.opt.normalize(test.word)
+			if result != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, result)
+			}
+
+			if names := test.opt.stepNames(applied); !sliceEquals(test.applied, names) {
+				t.Errorf("expected steps %v, got %v", test.applied, names)
+			}
+		})
+	}
+}
+
+func TestAnagramIndexReport(t *testing.T) {
+	idx := NewAnagramIndexWithOptions(NormalizeOptions{Form: FormNFC, FoldYo: true, LettersOnly: true})
+	for _, word := range []string{"Осёл", "кот", "т-ок", "осе\u0308л", "ёлка", "е\u0308лка", "село", "лес"} {
+		idx.Add(word)
+	}
+
+	// Написания NFC и NFD одного слова - повтор, а не анаграммы
+	expected := []GroupReport{
+		{Key: "кот", Words: []string{"кот", "т-ок"}, Normalized: "кот", Applied: []string{"letters-only"}},
+		{Key: "осёл", Words: []string{"осёл", "село"}, Normalized: "осел", Applied: []string{"yo"}},
+	}
+	if report := idx.Report(); !reflect.DeepEqual(expected, report) {
+		t.Errorf("expected %v, got %v", expected, report)
+	}
+
+	if expected, result := []string{"кот", "т-ок"}, idx.Lookup("ТОК!"); !sliceEquals(expected, result) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if s := idx.Options().String(); s != "lower+yo+letters-only+nfc" {
+		t.Errorf("unexpected options %q", s)
+	}
+
+	// Фраза нормализуется так же, как слова индекса
+	phrases, err := idx.PhraseAnagrams("лёк ток-а", SearchLimits{MaxWords: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedPhrases := [][]string{{"кот", "ёлка"}, {"т-ок", "ёлка"}}
+	if !reflect.DeepEqual(expectedPhrases, phrases) {
+		t.Errorf("expected %q, got %q", expectedPhrases, phrases)
+	}
+}
+
+func TestSubAnagrams(t *testing.T) {
+	idx := NewAnagramIndex()
+	for _, word := range []string{"пятак", "тяпка", "пят", "тяп", "як", "кот", "пятка", "пятаки", "я"} {
+		idx.Add(word)
+	}
+
+	words, err := idx.SubAnagrams("ПЯТКА", SearchLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"пятак", "пятка", "тяпка", "пят", "тяп", "як", "я"}
+	if !sliceEquals(expected, words) {
+		t.Errorf("expected %v, got %v", expected, words)
+	}
+
+	words, err = idx.SubAnagrams("пятка", SearchLimits{MaxResults: 2})
+	if !errors.Is(err, ErrSearchLimit) {
+		t.Errorf("expected ErrSearchLimit, got %v", err)
+	}
+	if expected := []string{"пятак", "пятка"}; !sliceEquals(expected, words) {
+		t.Errorf("expected %v, got %v", expected, words)
+	}
+}
+
+func TestPhraseAnagrams(t *testing.T) {
+	idx := NewAnagramIndex()
+	for _, word := range []string{"кот", "ток", "нос", "сон", "котсон", "о", "кто", "н", "с"} {
+		idx.Add(word)
+	}
+
+	tests := []struct {
+		name     string
+		phrase   string
+		limits   SearchLimits
+		expected [][]string
+		err      error
+	}{
+		{
+			name:   "two words",
+			phrase: "Кот  Сон",
+			limits: SearchLimits{MaxWords: 2},
+			expected: [][]string{
+				{"кот", "нос"}, {"кот", "сон"}, {"котсон"}, {"кто", "нос"}, {"кто", "сон"}, {"нос", "ток"}, {"сон", "ток"},
+			},
+		},
+		{
+			name:     "repeated word",
+			phrase:   "ктоток",
+			expected: [][]string{{"кот", "кот"}, {"кот", "кто"}, {"кот", "ток"}, {"кто", "кто"}, {"кто", "ток"}, {"ток", "ток"}},
+		},
+		{
+			name:     "no anagrams",
+			phrase:   "слон",
+			expected: nil,
+		},
+		{
+			name:     "max results",
+			phrase:   "кот сон",
+			limits:   SearchLimits{MaxWords: 2, MaxResults: 3},
+			expected: [][]string{{"кот", "нос"}, {"кот", "сон"}, {"кто", "нос"}},
+			err:      ErrSearchLimit,
+		},
+		{
+			name:   "max steps",
+			phrase: "кот сон",
+			limits: SearchLimits{MaxSteps: 1},
+			err:    ErrSearchLimit,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := idx.PhraseAnagrams(test.phrase, test.limits)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+
+			if !reflect.DeepEqual(test.expected, result) {
+				t.Errorf("expected %v, got %v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{a: "пятак", b: "тяпка", equal: true},
+		{a: "listen", b: "silent", equal: true},
+		{a: "ёж", b: "жё", equal: true},
+		{a: "ёж", b: "еж", equal: false},
+		{a: "éa1", b: "1aé", equal: true},
+		{a: "аа", b: "а", equal: false},
+		{a: "ab", b: "ba1", equal: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			if equal := signature(test.a) == signature(test.b); equal != test.equal {
+				t.Errorf("expected equal %v, got %v", test.equal, equal)
+			}
+		})
+	}
+}
+
+// Записывает словарь во временный файл и возвращает его имя
+func writeDict(t *testing.T, path, words string) string {
+	t.Helper()
+
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "dict.txt")
+	}
+	if err := os.WriteFile(path, []byte(words), 0o644); err != nil {
+		t.Fatalf("failed to write dictionary: %v", err)
+	}
+
+	return path
+}
+
+func TestIndexStore(t *testing.T) {
+	path := writeDict(t, "", "пятак пятка тяпка\nкот ток\nтокио")
+
+	store, err := LoadIndexStore(path, NormalizeOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected, result := []string{"кот", "ток"}, store.Lookup("окт"); !sliceEquals(expected, result) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if groups := store.Groups(3); len(groups) != 1 || groups[0].Key != "пятак" {
+		t.Errorf("expected only пятак group, got %v", groups)
+	}
+
+	// После перезагрузки используется новый словарь
+	writeDict(t, path, "кот ток отк")
+	if err := store.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected, result := []string{"кот", "отк", "ток"}, store.Lookup("кот"); !sliceEquals(expected, result) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if result := store.Lookup("пятак"); result != nil {
+		t.Errorf("expected no anagrams, got %v", result)
+	}
+
+	// Ошибка загрузки оставляет прежний словарь
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Reload(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %v, got %v", os.ErrNotExist, err)
+	}
+	if groups := store.Groups(1); len(groups) != 1 || groups[0].Key != "кот" {
+		t.Errorf("expected only кот group, got %v", groups)
+	}
+
+	if _, err := LoadIndexStore(path, NormalizeOptions{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected %v, got %v", os.ErrNotExist, err)
+	}
+}
+
+func TestIndexStoreConcurrentReload(t *testing.T) {
+	path := writeDict(t, "", "кот ток")
+
+	store, err := LoadIndexStore(path, NormalizeOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				// Любой из загружаемых словарей содержит множество кот
+				if result := store.Lookup("кот"); len(result) < 2 {
+					t.Errorf("expected anagrams of кот, got %v", result)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if err := store.Reload(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestHandler(t *testing.T) {
+	path := writeDict(t, "", "пятак пятка тяпка\nкот ток\nтокио")
+
+	store, err := LoadIndexStore(path, NormalizeOptions{FoldYo: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	router := NewHandler(store).InitRoutes()
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		status   int
+		expected string
+	}{
+		{
+			name:     "anagrams",
+			method:   http.MethodGet,
+			target:   "/anagrams?word=%D0%A2%D0%AF%D0%9F%D0%9A%D0%90",
+			status:   http.StatusOK,
+			expected: `{"result":{"word":"ТЯПКА","anagrams":["пятак","пятка","тяпка"],"normalization":"lower+yo"}}`,
+		},
+		{
+			name:     "no anagrams",
+			method:   http.MethodGet,
+			target:   "/anagrams?word=слон",
+			status:   http.StatusOK,
+			expected: `{"result":{"word":"слон","anagrams":[],"normalization":"lower+yo"}}`,
+		},
+		{
+			name:     "missing word",
+			method:   http.MethodGet,
+			target:   "/anagrams?word=+",
+			status:   http.StatusBadRequest,
+			expected: `{"error":"word is required"}`,
+		},
+		{
+			name:     "wrong method",
+			method:   http.MethodPost,
+			target:   "/anagrams?word=кот",
+			status:   http.StatusMethodNotAllowed,
+			expected: `{"error":"method not allowed"}`,
+		},
+		{
+			name:   "groups",
+			method: http.MethodGet,
+			target: "/groups",
+			status: http.StatusOK,
+			expected: `{"result":[` +
+				`{"key":"кот","words":["кот","ток"],"normalized":"кот","applied":null},` +
+				`{"key":"пятак","words":["пятак","пятка","тяпка"],"normalized":"пятак","applied":null}]}`,
+		},
+		{
+			name:     "groups min size",
+			method:   http.MethodGet,
+			target:   "/groups?min_size=3",
+			status:   http.StatusOK,
+			expected: `{"result":[{"key":"пятак","words":["пятак","пятка","тяпка"],"normalized":"пятак","applied":null}]}`,
+		},
+		{
+			name:     "groups empty",
+			method:   http.MethodGet,
+			target:   "/groups?min_size=4",
+			status:   http.StatusOK,
+			expected: `{"result":[]}`,
+		},
+		{
+			name:     "invalid min size",
+			method:   http.MethodGet,
+			target:   "/groups?min_size=0",
+			status:   http.StatusBadRequest,
+			expected: `{"error":"min_size must be a positive number"}`,
+		},
+		{
+			name:     "groups wrong method",
+			method:   http.MethodDelete,
+			target:   "/groups",
+			status:   http.StatusMethodNotAllowed,
+			expected: `{"error":"method not allowed"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(test.method, test.target, nil))
+
+			if recorder.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, recorder.Code)
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("unexpected content type %q", contentType)
+			}
+			if body := strings.TrimSpace(recorder.Body.String()); body != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, body)
+			}
+		})
+	}
+}
+
+func TestRunCLI(t *testing.T) {
+	path := writeDict(t, "", "пятак пятка тяпка\nкот ток\nтокио")
+
+	store, err := LoadIndexStore(path, NormalizeOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out strings.Builder
+	if err := runCLI(store, strings.NewReader("окт ТЯПКА\n\nслон\n"), &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "окт: кот ток\nТЯПКА: пятак пятка тяпка\nслон: \n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestParseForm(t *testing.T) {
+	tests := []struct {
+		str      string
+		expected Form
+		err      error
+	}{
+		{str: "", expected: FormNone},
+		{str: "none", expected: FormNone},
+		{str: "NFC", expected: FormNFC},
+		{str: "nfkd", expected: FormNFKD},
+		{str: "nfd", err: ErrUnknownForm},
+	}
+
+	for _, test := range tests {
+		t.Run(test.str, func(t *testing.T) {
+			form, err := ParseForm(test.str)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if form != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, form)
+			}
+		})
+	}
+}
+
+// Словарь из n слов: случайные русские слова и их перестановки
+func benchmarkDict(n int) []string {
+	rnd := rand.New(rand.NewSource(1))
+	letters := []rune("абвгдеёжзийклмнопрстуфхцчшщъыьэюя")
+
+	dict := make([]string, 0, n)
+	for len(dict) < n {
+		word := make([]rune, 3+rnd.Intn(8))
+		for i := range word {
+			word[i] = letters[rnd.Intn(len(letters))]
+		}
+
+		for j := 0; j < 3 && len(dict) < n; j++ {
+			rnd.Shuffle(len(word), func(a, b int) { word[a], word[b] = word[b], word[a] })
+			dict = append(dict, string(word))
+		}
+	}
+
+	return dict
+}
+
+func BenchmarkFindAnagrams(b *testing.B) {
+	dict := benchmarkDict(100000)
+
+	for i := 0; i < b.N; i++ {
+		FindAnagrams(&dict)
+	}
+}
+
+func BenchmarkFindAnagramsSorted(b *testing.B) {
+	dict := benchmarkDict(100000)
+
+	for i := 0; i < b.N; i++ {
+		findAnagramsSorted(&dict)
+	}
+}
+
+func BenchmarkAnagramIndex(b *testing.B) {
+	dict := benchmarkDict(100000)
+
+	for i := 0; i < b.N; i++ {
+		idx := NewAnagramIndex()
+		for _, word := range dict {
+			idx.Add(word)
+		}
+		idx.Groups()
+	}
+}
+
+func BenchmarkAnagramIndexReadWords(b *testing.B) {
+	text := strings.Join(benchmarkDict(100000), "\n")
+
+	for i := 0; i < b.N; i++ {
+		if _, err := NewAnagramIndex().ReadWords(strings.NewReader(text)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSortChars(b *testing.B) {
+	dict := benchmarkDict(1000)
+
+	for i := 0; i < b.N; i++ {
+		for _, word := range dict {
+			sortChars(word)
+		}
+	}
+}
+
+func BenchmarkSignature(b *testing.B) {
+	dict := benchmarkDict(1000)
+
+	for i := 0; i < b.N; i++ {
+		for _, word := range dict {
+			signature(word)
+		}
+	}
+}
+
+/*
+Прежняя реализация поиска анаграмм через сортировку словаря,
+оставлена для сравнения в бенчмарках.
+*/
+func findAnagramsSorted(dict *[]string) *map[string][]string {
+	// Приводим массив строк к нижнему регистру
+	dict = toLower(dict)
+
+	// Применяем быструю сортировку к массиву строк
+	dict = quickSort(*dict, 0, len(*dict)-1)
+
+	// Создаем временную карты для хранения анаграмм
+	tempMap := make(map[string][]string)
+	nameMap := make(map[string]string)
+
+	// Поиск анаграмм в массиве строк
+	for _, word := range *dict {
+		if len(word) > 1 {
+			sortedWord := sortChars(word)
+			if _, ok := tempMap[sortedWord]; ok {
+				tempMap[sortedWord] = append(tempMap[sortedWord], word)
+				continue
+			}
+			tempMap[sortedWord] = make([]string, 0, 1)
+			nameMap[sortedWord] = word
+		}
+	}
+
+	// Формируем результирующую карту анаграмм
+	resultMap := make(map[string][]string)
+	for sortedWord, word := range tempMap {
+		if len(word) != 0 {
+			resultMap[nameMap[sortedWord]] = word
+		}
+
+	}
+
+	return &resultMap
+}
+
+// Сортировка массива строк с использование алгоритма быстрой сортировки
+func quickSort(words []string, start, end int) *[]string {
+	if start < end {
+		// Выбор опорного элемента
+		pivot := words[start]
+		left := start + 1
+		right := end
+
+		for left <= right {
+			for left <= right && words[left] <= pivot {
+				left++
+			}
+
+			for left <= right && words[right] >= pivot {
+				right--
+			}
+
+			if left < right {
+				words[left], words[right] = words[right], words[left]
+			}
+		}
+
+		words[start], words[right] = words[right], words[start]
+
+		// Рекурсивное применение quickSort к двум частям массива
+		words = *quickSort(words, start, right-1)
+		words = *quickSort(words, right+1, end)
+	}
+
+	return &words
+}
+
+// Приводит строки к нижнему регистру
+func toLower(words *[]string) *[]string {
+	result := make([]string, len(*words))
+
+	for i, str := range *words {
+		result[i] = strings.ToLower(str)
+	}
+
+	return &result
+}
+
+// Сортирует символы в строке
+func sortChars(word string) string {
+	chars := strings.Split(word, "")
+	sort.Strings(chars)
+
+	return strings.Join(chars, "")
+}
@@ -4,7 +4,9 @@ go 1.21.4
 
 require (
 	github.com/beevik/ntp v1.3.1
+	github.com/rivo/uniseg v0.4.7
 	golang.org/x/net v0.20.0
+	golang.org/x/text v0.14.0
 )
 
 require golang.org/x/sys v0.16.0 // indirect